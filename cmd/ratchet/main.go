@@ -0,0 +1,186 @@
+// Command ratchet runs, validates, and visualizes pipelines declared
+// in YAML or JSON config files. See ratchet.LayoutConfig for the format.
+//
+// Usage:
+//
+//	ratchet run [-stats] [-stats-json file] [-log-level level] [-grace duration] pipeline.yaml
+//	ratchet validate pipeline.yaml
+//	ratchet dot pipeline.yaml | dot -Tpng > pipeline.png
+//	ratchet types
+//
+// Processor types are looked up in the processors package registry (see
+// processors.Register). To make your own DataProcessors available, build
+// a copy of this command that registers them in an init func.
+//
+// On an interrupt or SIGTERM, run stops reading input and waits for the
+// pipeline to finish (e.g. flushing its output) for up to the -grace period.
+// A second signal stops it immediately.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+)
+
+const usage = `usage: ratchet <command> [flags] <config file>
+
+Commands:
+  run       build and run the pipeline
+  validate  check that the pipeline config is valid
+  dot       print the pipeline layout as a Graphviz DOT graph
+  types     list the registered processor types
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = run(os.Args[2:])
+	case "validate":
+		err = validate(os.Args[2:])
+	case "dot":
+		err = dot(os.Args[2:])
+	case "types":
+		for _, name := range processors.Registered() {
+			fmt.Println(name)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ratchet:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	stats := fs.Bool("stats", false, "print pipeline stats when complete")
+	statsJSON := fs.String("stats-json", "", "write pipeline stats as JSON to `file` when complete")
+	logLevel := fs.String("log-level", "status", "one of debug, info, error, status, or silent")
+	grace := fs.Duration("grace", ratchet.DefaultShutdownGracePeriod, "how long to wait for the pipeline to finish after an interrupt")
+	config, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := setLogLevel(*logLevel); err != nil {
+		return err
+	}
+
+	builder := &processors.Builder{}
+	defer builder.Close()
+	layout, err := config.Layout(builder.New)
+	if err != nil {
+		return err
+	}
+	pipeline := ratchet.NewBranchingPipeline(context.Background(), nil, layout)
+	if config.Name != "" {
+		pipeline.Name = config.Name
+	}
+	pipeline.BufferLength = config.BufferLength
+	pipeline.ErrorPolicy = config.ErrorPolicy
+	pipeline.MaxErrors = config.MaxErrors
+	pipeline.MaxPayloadSize = config.MaxPayloadSize
+	pipeline.PayloadSizePolicy = config.PayloadSizePolicy
+	if config.DeadLetter != "" {
+		f, err := os.Create(config.DeadLetter)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		pipeline.DeadLetter = f
+	}
+
+	pipeline.ShutdownGracePeriod = *grace
+	pipeline.HandleSignals(os.Interrupt, syscall.SIGTERM)
+
+	err = <-pipeline.Run()
+	if closeErr := builder.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if errs, ok := err.(*ratchet.PipelineErrors); ok {
+		for _, e := range errs.Errors {
+			fmt.Fprintln(os.Stderr, e)
+		}
+	}
+	if *stats {
+		fmt.Fprint(os.Stderr, pipeline.Stats())
+	}
+	if *statsJSON != "" {
+		d, jsonErr := pipeline.StatsJSON()
+		if jsonErr == nil {
+			jsonErr = ioutil.WriteFile(*statsJSON, d, 0666)
+		}
+		if jsonErr != nil && err == nil {
+			err = jsonErr
+		}
+	}
+	if err == ratchet.ErrShutdown {
+		fmt.Fprintln(os.Stderr, "ratchet: shut down cleanly")
+		return nil
+	}
+	return err
+}
+
+func validate(args []string) error {
+	config, err := parseConfig(flag.NewFlagSet("validate", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	if _, err := config.Layout(checkProcessor); err != nil {
+		return err
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func dot(args []string) error {
+	config, err := parseConfig(flag.NewFlagSet("dot", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	return config.WriteDOT(os.Stdout)
+}
+
+func parseConfig(fs *flag.FlagSet, args []string) (*ratchet.LayoutConfig, error) {
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	b, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+	return ratchet.ParseLayoutConfig(b)
+}
+
+func setLogLevel(level string) error {
+	levels := map[string]int{
+		"debug":  logger.LevelDebug,
+		"info":   logger.LevelInfo,
+		"error":  logger.LevelError,
+		"status": logger.LevelStatus,
+		"silent": logger.LevelSilent,
+	}
+	l, ok := levels[level]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	logger.LogLevel = l
+	return nil
+}
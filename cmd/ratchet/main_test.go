@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ratchet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "output.txt")
+
+	tests := []struct {
+		name      string
+		processor string
+		err       string
+	}{
+		{"valid", `{type: io_writer, params: {path: ` + output + `}}`, ""},
+		{"unknown type", `{type: io_wrter}`, `unknown processor type "io_wrter"`},
+		{"missing param", `{type: io_writer}`, `missing required param "path"`},
+		{"wrong type", `{type: io_writer, params: {path: ` + output + `, add_newline: "yes"}}`, "cannot unmarshal"},
+		{"bad duration", `{type: dir_watcher, params: {dir: ` + dir + `, interval: soon}}`, "invalid duration"},
+		{"unknown driver", `{type: sql_writer, params: {driver: nosql, dsn: x, table: t}}`, `unknown driver "nosql"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := `
+stages:
+  - processors:
+      - {name: read, type: io_reader, params: {path: "-"}, outputs: [write]}
+  - processors:
+      - ` + strings.Replace(test.processor, "{", "{name: write, ", 1) + `
+`
+			path := filepath.Join(dir, "pipeline.yaml")
+			if err := ioutil.WriteFile(path, []byte(config), 0666); err != nil {
+				t.Fatal(err)
+			}
+			err := validate([]string{path})
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+
+	// Validating mustn't create (or truncate) any files.
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("validate created %v", output)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/processors"
+)
+
+// checkProcessor is the ratchet.ProcessorBuilder used for validating config
+// files. It checks the type is registered and its params are valid (see
+// processors.Validate) without constructing the DataProcessor, so validation
+// never opens connections or truncates files.
+func checkProcessor(typeName string, params map[string]interface{}) (ratchet.DataProcessor, error) {
+	if _, ok := processors.Lookup(typeName); !ok {
+		return nil, fmt.Errorf("unknown processor type %q", typeName)
+	}
+	if err := processors.Validate(typeName, params); err != nil {
+		return nil, err
+	}
+	return &placeholder{typeName}, nil
+}
+
+// placeholder stands in for a DataProcessor when validating a layout.
+type placeholder struct {
+	typeName string
+}
+
+func (p *placeholder) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (p *placeholder) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (p *placeholder) String() string {
+	return p.typeName
+}
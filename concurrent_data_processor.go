@@ -37,6 +37,16 @@ type concurrentDataProcessor struct {
 	sync.Mutex
 }
 
+// setConcurrency sets the number of concurrent ProcessData calls, overriding
+// the DataProcessor's own Concurrency (if it's a ConcurrentDataProcessor).
+func (dp *dataProcessor) setConcurrency(concurrency int) {
+	dp.concurrency = concurrency
+	dp.workThrottle = make(chan workSignal, concurrency)
+	dp.workList = list.New()
+	dp.doneChan = make(chan bool)
+	dp.inputClosed = false
+}
+
 type workSignal struct{}
 
 type result struct {
@@ -48,11 +58,16 @@ type result struct {
 
 func (dp *dataProcessor) processData(d data.JSON, killChan chan error) {
 	logger.Debug("dataProcessor: processData", dp, "with concurrency =", dp.concurrency)
+	parents := dp.lineage.receive(d)
 	// If no concurrency is needed, simply call stage.ProcessData and return...
 	if dp.concurrency <= 1 {
+		outputChan, done := dp.lineageOutput(parents)
 		dp.recordExecution(func() {
-			dp.ProcessData(d, dp.outputChan, killChan, dp.ctx)
+			dp.ProcessData(d, outputChan, killChan, dp.processCtx)
 		})
+		if done() {
+			dp.lineage.forget(parents)
+		}
 		return
 	}
 	// ... otherwise process the data in a concurrent queue/pool of goroutines
@@ -66,6 +81,7 @@ func (dp *dataProcessor) processData(d data.JSON, killChan chan error) {
 	// setup goroutine to handle result
 	go func() {
 		res := result{outputChan: dp.outputChan, data: []data.JSON{}, open: true}
+		sent := false
 		dp.Lock()
 		dp.workList.PushBack(&res)
 		dp.Unlock()
@@ -74,11 +90,23 @@ func (dp *dataProcessor) processData(d data.JSON, killChan chan error) {
 			select {
 			case d, open := <-rc:
 				logger.Debug("dataProcessor: processData", dp, "received data on result chan")
-				res.data = append(res.data, d)
+				if open {
+					sent = true
+					res.data = append(res.data, dp.emit(d, parents)...)
+				} else {
+					res.data = append(res.data, d)
+				}
 				// outputChan will need to be closed if the rc chan was closed
 				res.open = open
 			case <-done:
+				// sendResults checks done (under the lock)
+				// from other processData goroutines.
+				dp.Lock()
 				res.done = true
+				dp.Unlock()
+				if sent {
+					dp.lineage.forget(parents)
+				}
 				logger.Debug("dataProcessor: processData", dp, "done, releasing work")
 				<-dp.workThrottle
 				dp.sendResults()
@@ -93,7 +121,7 @@ func (dp *dataProcessor) processData(d data.JSON, killChan chan error) {
 	// do normal data processing, passing in new result chan
 	// instead of the original outputChan
 	go dp.recordExecution(func() {
-		dp.ProcessData(d, rc, killChan, dp.ctx)
+		dp.ProcessData(d, rc, killChan, dp.processCtx)
 		select {
 		case done <- true:
 		case <-dp.ctx.Done():
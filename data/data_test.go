@@ -39,7 +39,7 @@ func ExampleObjectsFromJSON() {
 	// Output: [map[One:1] map[Two:2]]
 }
 
-func ExampleObjectsFromJSONIsNull() {
+func ExampleObjectsFromJSON_null() {
 	d := []byte("null")
 
 	objects, _ := data.ObjectsFromJSON(d)
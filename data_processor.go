@@ -1,7 +1,6 @@
 package ratchet
 
 import (
-	"container/list"
 	"context"
 	"fmt"
 	"sync"
@@ -17,6 +16,9 @@ type DataProcessor interface {
 	// ProcessData is called with a data.JSON instance, which is the data being received,
 	// an outputChan, which is the channel to send data to, and a killChan,
 	// which is a channel to send unexpected errors to (halting execution of the Pipeline).
+	// Sending an error doesn't stop ProcessData, so it should return once it has
+	// sent one (see util.KillPipelineIfErr): with the CollectErrors ErrorPolicy the
+	// Pipeline keeps running, and ProcessData will be called with the next payload.
 	ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context)
 
 	// Finish will be called after the previous stage has finished sending data,
@@ -26,6 +28,34 @@ type DataProcessor interface {
 	Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context)
 }
 
+// Source is a DataProcessor that produces data itself, rather than processing
+// data received from a previous stage. When a Source is in the first
+// PipelineStage, Start is called once in place of ProcessData (so it isn't
+// sent the StartSignal), and Finish is called after Start returns.
+// Start should return once all data has been sent, or when ctx is done.
+//
+// A Source in a later stage is treated as a regular DataProcessor, and
+// DataProcessors in the first stage that aren't Sources are still sent
+// the StartSignal.
+type Source interface {
+	DataProcessor
+	Start(outputChan chan data.JSON, killChan chan error, ctx context.Context)
+}
+
+// Committer is a DataProcessor that holds back work until the whole Pipeline
+// is done with the data it sent, such as recording which input has been
+// processed (see processors.DirWatcher). Commit is called once every stage
+// has finished, when Run completes successfully, with collected errors (see
+// CollectErrors), or after a Shutdown that finished within the grace period.
+// It isn't called if the Pipeline is halted by an error or cancelled.
+//
+// An error returned by Commit is sent on Run's killChan in place of the
+// Pipeline's result.
+type Committer interface {
+	DataProcessor
+	Commit() error
+}
+
 // dataProcessor is a type used internally to the Pipeline management
 // code, and wraps a DataProcessor instance. DataProcessor is the main
 // interface that should be implemented to perform work within the data
@@ -41,6 +71,70 @@ type dataProcessor struct {
 	inputChan  chan data.JSON
 	outputChan chan data.JSON
 	ctx        context.Context
+	processCtx context.Context // Passed to ProcessData and Start, see Pipeline.Shutdown.
+	killChan   chan error
+	lineage    *lineageNode
+	limiter    *payloadLimiter
+	stage      int    // Starts at 1.
+	label      string // See processorLabel.
+}
+
+// start calls Start on the wrapped Source.
+func (dp *dataProcessor) start(source Source, killChan chan error) {
+	outputChan, done := dp.lineageOutput(nil)
+	dp.recordExecution(func() {
+		source.Start(outputChan, killChan, dp.processCtx)
+	})
+	done()
+}
+
+// finish calls Finish on the wrapped DataProcessor.
+func (dp *dataProcessor) finish(killChan chan error) {
+	outputChan, done := dp.lineageOutput(dp.lineage.receivedIDs())
+	dp.Finish(outputChan, killChan, dp.ctx)
+	done()
+}
+
+// lineageOutput returns the channel to hand the wrapped DataProcessor in place
+// of outputChan. When lineage is enabled, every payload sent on it is limited
+// and recorded as derived from parents (see emit), then forwarded on to
+// outputChan. The returned func must be called once the DataProcessor is done
+// sending, and reports whether anything was sent.
+func (dp *dataProcessor) lineageOutput(parents []string) (chan data.JSON, func() bool) {
+	if dp.lineage == nil {
+		return dp.outputChan, func() bool { return false }
+	}
+	c := make(chan data.JSON)
+	done := make(chan struct{})
+	sent := false
+	go func() {
+		defer close(done)
+		for d := range c {
+			sent = true
+			for _, d := range dp.emit(d, parents) {
+				select {
+				case dp.outputChan <- d:
+				case <-dp.ctx.Done():
+				}
+			}
+		}
+	}()
+	return c, func() bool {
+		close(c)
+		<-done
+		return sent
+	}
+}
+
+// emit applies the payload size limit to d, sent by the wrapped
+// DataProcessor, and records the lineage of the payloads to send in its place.
+// Payloads that don't go through emit are limited by branchOut instead.
+func (dp *dataProcessor) emit(d data.JSON, parents []string) []data.JSON {
+	payloads := dp.limiter.limit(d, dp)
+	for _, d := range payloads {
+		dp.lineage.send(d, parents)
+	}
+	return payloads
 }
 
 type chanBrancher struct {
@@ -56,18 +150,20 @@ func (dp *dataProcessor) branchOut() {
 				if !ok {
 					break processLoop
 				}
-				for _, out := range dp.branchOutChans {
-					// Make a copy to ensure concurrent stages
-					// can alter data as needed.
-					dc := make(data.JSON, len(d))
-					copy(dc, d)
-					select {
-					case out <- dc:
-					case <-dp.ctx.Done():
-						return
+				for _, d := range dp.limiter.limit(d, dp) {
+					for _, out := range dp.branchOutChans {
+						// Make a copy to ensure concurrent stages
+						// can alter data as needed.
+						dc := make(data.JSON, len(d))
+						copy(dc, d)
+						select {
+						case out <- dc:
+						case <-dp.ctx.Done():
+							return
+						}
 					}
+					dp.recordDataSent(d)
 				}
-				dp.recordDataSent(d)
 			case <-dp.ctx.Done():
 				return
 			}
@@ -128,11 +224,7 @@ func Do(processor DataProcessor) *dataProcessor {
 	dp.inputChan = make(chan data.JSON)
 
 	if isConcurrent(processor) {
-		dp.concurrency = processor.(ConcurrentDataProcessor).Concurrency()
-		dp.workThrottle = make(chan workSignal, dp.concurrency)
-		dp.workList = list.New()
-		dp.doneChan = make(chan bool)
-		dp.inputClosed = false
+		dp.setConcurrency(processor.(ConcurrentDataProcessor).Concurrency())
 	}
 
 	return &dp
@@ -0,0 +1,181 @@
+package ratchet
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrorPolicy controls what a Pipeline does when a DataProcessor sends an error
+// on its killChan.
+type ErrorPolicy int
+
+const (
+	// KillOnError halts the Pipeline on the first error. This is the default.
+	KillOnError ErrorPolicy = iota
+	// CollectErrors keeps the Pipeline running past errors, recording each one
+	// (up to Pipeline.MaxErrors) and counting them per DataProcessor. When the
+	// Pipeline completes, Run's killChan receives a *PipelineErrors if any
+	// errors were recorded, or nil otherwise. DataProcessors must return after
+	// sending an error, rather than carrying on with e.g. a file that failed
+	// to open, for their errors to be collected safely.
+	CollectErrors
+)
+
+func (p ErrorPolicy) String() string {
+	switch p {
+	case KillOnError:
+		return "kill"
+	case CollectErrors:
+		return "collect"
+	}
+	return fmt.Sprintf("ErrorPolicy(%d)", int(p))
+}
+
+// MarshalJSON encodes the policy as "kill" or "collect".
+func (p ErrorPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON decodes a policy from "kill" or "collect".
+func (p *ErrorPolicy) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	switch s {
+	case "kill", "":
+		*p = KillOnError
+	case "collect":
+		*p = CollectErrors
+	default:
+		return fmt.Errorf("unknown error policy %q, must be kill or collect", s)
+	}
+	return nil
+}
+
+// DefaultMaxErrors is the number of errors kept by a Pipeline using
+// CollectErrors if MaxErrors isn't set.
+var DefaultMaxErrors = 1000
+
+// PayloadError is an error sent by a DataProcessor while the Pipeline was
+// collecting errors.
+type PayloadError struct {
+	Stage     int    `json:"stage"`     // Starts at 1, matching Pipeline.Stats.
+	Processor string `json:"processor"` // See PipelineErrors.Counts.
+	Message   string `json:"error"`
+	Err       error  `json:"-"`
+}
+
+func (e PayloadError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Processor, e.Err)
+}
+
+// PipelineErrors is the result of a Pipeline run using CollectErrors that
+// encountered errors. Errors holds the first MaxErrors errors in the order
+// they were received, while Total and Counts cover every error.
+type PipelineErrors struct {
+	Errors []PayloadError `json:"errors"`
+	// Counts maps each DataProcessor that sent errors (named "<stage>.<index> <name>",
+	// e.g. "2.1 SQLWriter") to the number it sent.
+	Counts map[string]int `json:"counts"`
+	Total  int            `json:"total"`
+	// Shutdown is set if the Pipeline was shut down (see Pipeline.Shutdown),
+	// so Run returned these errors in place of ErrShutdown.
+	Shutdown bool `json:"shutdown,omitempty"`
+}
+
+// Error summarizes the errors, listing the count for each DataProcessor.
+func (e *PipelineErrors) Error() string {
+	names := make([]string, 0, len(e.Counts))
+	for name := range e.Counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	counts := make([]string, len(names))
+	for i, name := range names {
+		counts[i] = fmt.Sprintf("%v: %d", name, e.Counts[name])
+	}
+	msg := fmt.Sprintf("%d errors (%v)", e.Total, strings.Join(counts, ", "))
+	if e.Shutdown {
+		msg += " before the pipeline was shut down"
+	}
+	return msg
+}
+
+// errorCollector records the errors sent by each DataProcessor.
+type errorCollector struct {
+	max     int
+	errors  PipelineErrors
+	running sync.WaitGroup
+	done    chan struct{}
+	sync.Mutex
+}
+
+func newErrorCollector(max int) *errorCollector {
+	if max <= 0 {
+		max = DefaultMaxErrors
+	}
+	return &errorCollector{max: max, errors: PipelineErrors{Counts: make(map[string]int)}, done: make(chan struct{})}
+}
+
+// killChan returns the channel to hand to dp in place of the Pipeline's
+// killChan. Errors sent on it are recorded rather than halting the Pipeline.
+func (c *errorCollector) killChan(stage int, name string, dp *dataProcessor) chan error {
+	errs := make(chan error)
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		for {
+			select {
+			case err := <-errs:
+				dp.recordError()
+				c.record(PayloadError{Stage: stage, Processor: name, Message: err.Error(), Err: err})
+			case <-c.done:
+				return
+			case <-dp.ctx.Done():
+				return
+			}
+		}
+	}()
+	return errs
+}
+
+func (c *errorCollector) record(err PayloadError) {
+	c.Lock()
+	defer c.Unlock()
+	c.errors.Total++
+	c.errors.Counts[err.Processor]++
+	if len(c.errors.Errors) < c.max {
+		c.errors.Errors = append(c.errors.Errors, err)
+	}
+}
+
+// stop waits for the collectors to finish, and returns
+// the errors recorded, or nil if there were none.
+func (c *errorCollector) stop() error {
+	close(c.done)
+	c.running.Wait()
+	result := c.result()
+	if result.Total == 0 {
+		return nil
+	}
+	return result
+}
+
+// result returns a copy of the errors recorded so far.
+func (c *errorCollector) result() *PipelineErrors {
+	c.Lock()
+	defer c.Unlock()
+	result := &PipelineErrors{
+		Errors: append([]PayloadError{}, c.errors.Errors...),
+		Counts: make(map[string]int, len(c.errors.Counts)),
+		Total:  c.errors.Total,
+	}
+	for name, n := range c.errors.Counts {
+		result.Counts[name] = n
+	}
+	return result
+}
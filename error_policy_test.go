@@ -0,0 +1,74 @@
+package ratchet_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+func TestCollectErrorsMaxErrors(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	payloads := make([]string, 20)
+	for i := range payloads {
+		payloads[i] = `{"a":1}`
+	}
+	capture := ratchettest.NewCapture()
+	p := ratchet.NewPipeline(context.Background(), nil, ratchettest.FeedJSON(payloads...), &failOdd{}, capture)
+	p.ErrorPolicy = ratchet.CollectErrors
+	p.MaxErrors = 3
+
+	err := ratchettest.RunPipeline(t, p)
+	errs, ok := err.(*ratchet.PipelineErrors)
+	if !ok {
+		t.Fatalf("got error %v, want *PipelineErrors", err)
+	}
+	if errs.Total != 10 || errs.Counts["2.1 failOdd"] != 10 || len(errs.Counts) != 1 {
+		t.Errorf("got total %d and counts %v, want 10 errors from 2.1 failOdd", errs.Total, errs.Counts)
+	}
+	if len(errs.Errors) != 3 {
+		t.Fatalf("kept %d errors, want 3", len(errs.Errors))
+	}
+	for _, e := range errs.Errors {
+		if e.Stage != 2 || e.Processor != "2.1 failOdd" || e.Message != "odd payload" {
+			t.Errorf("got error %+v", e)
+		}
+	}
+	if got := len(capture.Payloads()); got != 10 {
+		t.Errorf("captured %d payloads, want 10", got)
+	}
+}
+
+func TestCollectErrorsNoErrors(t *testing.T) {
+	p := ratchet.NewPipeline(context.Background(), nil, ratchettest.FeedJSON(`{"a":1}`), processors.NewPassthrough(), ratchettest.NewCapture())
+	p.ErrorPolicy = ratchet.CollectErrors
+	if err := ratchettest.RunPipeline(t, p); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+// TestCollectErrorsReturnsAfterError checks that a DataProcessor failing to
+// open its file returns, rather than carrying on with a nil file and
+// panicking, so the Pipeline keeps collecting errors.
+func TestCollectErrorsReturnsAfterError(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	capture := ratchettest.NewCapture()
+	p := ratchet.NewPipeline(context.Background(), nil,
+		ratchettest.FeedJSON(`{}`, `{}`), processors.NewCSVReader("testdata/missing.csv"), capture)
+	p.ErrorPolicy = ratchet.CollectErrors
+
+	err := ratchettest.RunPipeline(t, p)
+	errs, ok := err.(*ratchet.PipelineErrors)
+	if !ok {
+		t.Fatalf("got error %v, want *PipelineErrors", err)
+	}
+	if errs.Counts["2.1 CSVReader"] != 2 {
+		t.Errorf("got counts %v, want 2 errors from 2.1 CSVReader", errs.Counts)
+	}
+	if len(capture.Payloads()) != 0 {
+		t.Errorf("got payloads %q", capture.Payloads())
+	}
+}
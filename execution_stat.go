@@ -1,48 +1,79 @@
 package ratchet
 
 import (
+	"sync"
 	"time"
 
 	"github.com/rhansen2/ratchet/data"
 )
 
+// executionStat counts the work done by a dataProcessor. It's updated by
+// the processor's goroutines (and the error collector) while the pipeline
+// runs, and read by StatsSnapshot, so every field is guarded by mu.
 type executionStat struct {
+	mu                  sync.Mutex
 	dataSentCounter     int
 	dataReceivedCounter int
 	executionsCounter   int
 	totalExecutionTime  float64
-	avgExecutionTime    float64
 	totalBytesReceived  int
-	avgBytesReceived    int
 	totalBytesSent      int
-	avgBytesSent        int
+	errorsCounter       int
 }
 
 func (s *executionStat) recordExecution(foo func()) {
+	s.mu.Lock()
 	s.executionsCounter++
+	s.mu.Unlock()
 	st := time.Now()
 	foo()
-	s.totalExecutionTime += time.Now().Sub(st).Seconds()
+	elapsed := time.Now().Sub(st).Seconds()
+	s.mu.Lock()
+	s.totalExecutionTime += elapsed
+	s.mu.Unlock()
 }
 
 func (s *executionStat) recordDataSent(d data.JSON) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.dataSentCounter++
 	s.totalBytesSent += len(d)
 }
 
 func (s *executionStat) recordDataReceived(d data.JSON) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.dataReceivedCounter++
 	s.totalBytesReceived += len(d)
 }
 
-func (s *executionStat) calculate() {
+func (s *executionStat) recordError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errorsCounter++
+}
+
+// stats returns the current counts, along with their averages.
+func (s *executionStat) stats() ProcessorStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps := ProcessorStats{
+		Executions:            s.executionsCounter,
+		TotalExecutionSeconds: s.totalExecutionTime,
+		PayloadsSent:          s.dataSentCounter,
+		PayloadsReceived:      s.dataReceivedCounter,
+		TotalBytesSent:        s.totalBytesSent,
+		TotalBytesReceived:    s.totalBytesReceived,
+		Errors:                s.errorsCounter,
+	}
 	if s.executionsCounter > 0 {
-		s.avgExecutionTime = (s.totalExecutionTime / float64(s.executionsCounter))
+		ps.AvgExecutionSeconds = (s.totalExecutionTime / float64(s.executionsCounter))
 	}
 	if s.dataReceivedCounter > 0 {
-		s.avgBytesReceived = (s.totalBytesReceived / s.dataReceivedCounter)
+		ps.AvgBytesReceived = (s.totalBytesReceived / s.dataReceivedCounter)
 	}
 	if s.dataSentCounter > 0 {
-		s.avgBytesSent = (s.totalBytesSent / s.dataSentCounter)
+		ps.AvgBytesSent = (s.totalBytesSent / s.dataSentCounter)
 	}
+	return ps
 }
@@ -0,0 +1,199 @@
+package ratchet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/rhansen2/ratchet/data"
+	"gopkg.in/yaml.v3"
+)
+
+// ProcessorBuilder constructs a DataProcessor given the type name
+// and parameters from a ProcessorConfig. See LoadLayout.
+type ProcessorBuilder func(typeName string, params map[string]interface{}) (DataProcessor, error)
+
+// LayoutConfig describes a PipelineLayout in YAML or JSON, so that pipelines
+// can be assembled from a library of registered DataProcessors without writing
+// Go code. For example:
+//
+//	name: uppercase
+//	stages:
+//	  - processors:
+//	      - name: read
+//	        type: file_reader
+//	        params: {filename: input.txt}
+//	        outputs: [match]
+//	  - processors:
+//	      - name: match
+//	        type: regexp_matcher
+//	        params: {pattern: "ERROR"}
+//	        concurrency: 4
+//	        outputs: [write]
+//	  - processors:
+//	      - name: write
+//	        type: io_writer
+//	        params: {path: "-"}
+//
+// Outputs refer to processor names in the next stage, and follow the same
+// rules as NewPipelineLayout.
+type LayoutConfig struct {
+	Name              string            `json:"name"`
+	BufferLength      int               `json:"buffer_length"`
+	ErrorPolicy       ErrorPolicy       `json:"error_policy"` // "kill" (the default) or "collect"
+	MaxErrors         int               `json:"max_errors"`
+	MaxPayloadSize    int               `json:"max_payload_size"`
+	PayloadSizePolicy PayloadSizePolicy `json:"payload_size_policy"` // "reject" (the default), "truncate", or "split"
+	DeadLetter        string            `json:"dead_letter"`         // Path of a file to write rejected payloads to.
+	Stages            []StageConfig     `json:"stages"`
+}
+
+// StageConfig describes a single PipelineStage within a LayoutConfig.
+type StageConfig struct {
+	Processors []ProcessorConfig `json:"processors"`
+}
+
+// ProcessorConfig describes a single DataProcessor within a StageConfig.
+type ProcessorConfig struct {
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+	Concurrency int                    `json:"concurrency,omitempty"` // See ConcurrentDataProcessor
+	Outputs     []string               `json:"outputs,omitempty"`
+}
+
+// ParseLayoutConfig parses a LayoutConfig from YAML or JSON
+// (JSON being a subset of YAML) and checks that it is well formed.
+func ParseLayoutConfig(config []byte) (*LayoutConfig, error) {
+	// Decode the YAML generically and round-trip it through JSON,
+	// so the config types only need a single set of field tags.
+	var v interface{}
+	if err := yaml.Unmarshal(config, &v); err != nil {
+		return nil, err
+	}
+	d, err := data.NewJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	c := &LayoutConfig{}
+	if err := data.ParseJSON(d, c); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadLayout parses the given YAML or JSON LayoutConfig and builds a
+// validated PipelineLayout from it, using build to construct each DataProcessor.
+func LoadLayout(config []byte, build ProcessorBuilder) (*PipelineLayout, error) {
+	c, err := ParseLayoutConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return c.Layout(build)
+}
+
+// Layout builds a validated PipelineLayout from the LayoutConfig,
+// using build to construct each DataProcessor.
+func (c *LayoutConfig) Layout(build ProcessorBuilder) (*PipelineLayout, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	built := make(map[string]DataProcessor)
+	for _, s := range c.Stages {
+		for _, pc := range s.Processors {
+			p, err := build(pc.Type, pc.Params)
+			if err != nil {
+				return nil, fmt.Errorf("processor %q (%v): %v", pc.Name, pc.Type, err)
+			}
+			built[pc.Name] = p
+		}
+	}
+
+	stages := make([]*PipelineStage, len(c.Stages))
+	for i, s := range c.Stages {
+		dps := make([]*dataProcessor, len(s.Processors))
+		for j, pc := range s.Processors {
+			dps[j] = Do(built[pc.Name])
+			if pc.Concurrency > 1 {
+				dps[j].setConcurrency(pc.Concurrency)
+			}
+			if len(pc.Outputs) > 0 {
+				outputs := make([]DataProcessor, len(pc.Outputs))
+				for k, name := range pc.Outputs {
+					outputs[k] = built[name]
+				}
+				dps[j].Outputs(outputs...)
+			}
+		}
+		stages[i] = NewPipelineStage(dps...)
+	}
+	return NewPipelineLayout(stages...)
+}
+
+// WriteDOT writes the LayoutConfig as a Graphviz DOT graph, with one
+// cluster per stage, for visualizing a pipeline (e.g. "dot -Tpng").
+func (c *LayoutConfig) WriteDOT(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "digraph %q {\n\trankdir=LR;\n\tnode [shape=box];\n", c.Name)
+	for i, s := range c.Stages {
+		fmt.Fprintf(&b, "\tsubgraph cluster_%d {\n\t\tlabel=\"Stage %d\";\n", i+1, i+1)
+		for _, pc := range s.Processors {
+			label := pc.Name + "\\n(" + pc.Type + ")"
+			if pc.Concurrency > 1 {
+				label += fmt.Sprintf("\\nconcurrency=%d", pc.Concurrency)
+			}
+			fmt.Fprintf(&b, "\t\t%q [label=\"%s\"];\n", pc.Name, label)
+		}
+		fmt.Fprintf(&b, "\t}\n")
+	}
+	for _, s := range c.Stages {
+		for _, pc := range s.Processors {
+			for _, out := range pc.Outputs {
+				fmt.Fprintf(&b, "\t%q -> %q;\n", pc.Name, out)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// validate checks the parts of a LayoutConfig that NewPipelineLayout
+// can't: that names are unique and that outputs refer to known processors.
+func (c *LayoutConfig) validate() error {
+	if len(c.Stages) == 0 {
+		return fmt.Errorf("layout config must have at least one stage")
+	}
+	names := make(map[string]int)
+	for i, s := range c.Stages {
+		if len(s.Processors) == 0 {
+			return fmt.Errorf("stage #%d must have at least one processor", i+1)
+		}
+		for _, pc := range s.Processors {
+			if pc.Name == "" || pc.Type == "" {
+				return fmt.Errorf("stage #%d: every processor must have a name and type", i+1)
+			}
+			if _, ok := names[pc.Name]; ok {
+				return fmt.Errorf("processor name %q is used more than once", pc.Name)
+			}
+			names[pc.Name] = i
+		}
+	}
+	for i, s := range c.Stages {
+		for _, pc := range s.Processors {
+			for _, out := range pc.Outputs {
+				stage, ok := names[out]
+				if !ok {
+					return fmt.Errorf("processor %q outputs to unknown processor %q", pc.Name, out)
+				}
+				if stage != i+1 {
+					return fmt.Errorf("processor %q outputs to %q, which is not in the next stage #%d", pc.Name, out, i+2)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ratchet_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+func TestParseLayoutConfigErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{`stages: [`, "yaml"},
+		{`name: empty`, "at least one stage"},
+		{`stages: [{processors: []}]`, "stage #1 must have at least one processor"},
+		{`stages: [{processors: [{name: a}]}]`, "must have a name and type"},
+		{`stages: [{processors: [{name: a, type: passthrough}, {name: a, type: passthrough}]}]`, `"a" is used more than once`},
+		{`stages: [{processors: [{name: a, type: passthrough, outputs: [b]}]}]`, `unknown processor "b"`},
+		{`stages: [{processors: [{name: a, type: passthrough, outputs: [a]}]}]`, "not in the next stage"},
+		{`{"stages": [{"processors": [{"name": "a", "type": "passthrough", "concurrency": "many"}]}]}`, "cannot unmarshal"},
+	}
+	for _, test := range tests {
+		_, err := ratchet.ParseLayoutConfig([]byte(test.config))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseLayoutConfig(%q) = %v, want an error containing %q", test.config, err, test.err)
+		}
+	}
+}
+
+// source is a ratchet.Source that sends "started" from Start, and
+// fails the Pipeline if it's treated as a regular DataProcessor.
+type source struct{}
+
+func (s *source) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	outputChan <- data.JSON(`"started"`)
+}
+
+func (s *source) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	killChan <- errors.New("ProcessData called on a Source")
+}
+
+func (s *source) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func TestLoadLayout(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	capture := ratchettest.NewCapture()
+	build := func(typeName string, params map[string]interface{}) (ratchet.DataProcessor, error) {
+		switch typeName {
+		case "source":
+			return &source{}, nil
+		case "capture":
+			return capture, nil
+		}
+		return processors.New(typeName, params)
+	}
+	config := `
+name: configured
+stages:
+  - processors:
+      - {name: read, type: source, concurrency: 2, outputs: [match]}
+  - processors:
+      - {name: match, type: regexp_matcher, params: {pattern: start}, concurrency: 4, outputs: [capture]}
+  - processors:
+      - {name: capture, type: capture}
+`
+	layout, err := ratchet.LoadLayout([]byte(config), build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := ratchet.NewBranchingPipeline(context.Background(), nil, layout)
+	if err := ratchettest.RunPipeline(t, p); err != nil {
+		t.Fatal(err)
+	}
+	capture.AssertPayloads(t, `"started"`)
+
+	_, err = ratchet.LoadLayout([]byte(config), func(typeName string, params map[string]interface{}) (ratchet.DataProcessor, error) {
+		return nil, fmt.Errorf("no %v", typeName)
+	})
+	if err == nil || err.Error() != `processor "read" (source): no source` {
+		t.Errorf("got error %v", err)
+	}
+}
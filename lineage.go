@@ -0,0 +1,393 @@
+package ratchet
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+)
+
+// Lineage records where every payload sent through a Pipeline came from:
+// which DataProcessor produced it, which DataProcessors received it, and
+// which payloads it was derived from. To enable it, set Pipeline.Lineage
+// to the result of NewLineage before calling Run, and export the results
+// with WriteJSON or WriteOpenLineage once the Pipeline has completed.
+//
+// Every payload sent gets its own record (and ID), even if its content is
+// identical to another payload's, so each row can be audited. A payload
+// passed along unchanged gets a new record derived from the one received,
+// with the same SHA1.
+//
+// Payloads sent during ProcessData are recorded as derived from the payload
+// being processed, while payloads sent during Finish are recorded as derived
+// from every payload the DataProcessor received. This covers transform,
+// split, and batch/merge stages that emit their results in Finish.
+//
+// NewLineage keeps every record in memory until the Pipeline completes. For
+// long running Pipelines, use NewStreamingLineage instead, which also forgets
+// each payload a DataProcessor received once its ProcessData has sent
+// something derived from it. Payloads sent during Finish
+// are then derived only from the received payloads that ProcessData didn't
+// send anything for (i.e. those a batching stage is holding).
+type Lineage struct {
+	pipeline    string
+	started     time.Time
+	processors  []*lineageNode
+	records     map[string]*LineageRecord
+	order       []string
+	pending     map[pendingDelivery][]string
+	outstanding map[string]int
+	lastID      int
+	stream      io.Writer
+	streamErr   error
+	sync.Mutex
+}
+
+// LineageRecord describes a single payload tracked by Lineage.
+type LineageRecord struct {
+	ID          string    `json:"id"`
+	SHA1        string    `json:"sha1"`
+	Source      string    `json:"source"`
+	DerivedFrom []string  `json:"derived_from,omitempty"`
+	ReceivedBy  []string  `json:"received_by,omitempty"`
+	Bytes       int       `json:"bytes"`
+	Created     time.Time `json:"created"`
+}
+
+// pendingDelivery identifies payloads sent to a DataProcessor but not yet
+// received by it. Payloads are matched to their records by content, in the
+// order they were sent.
+type pendingDelivery struct {
+	to   *lineageNode
+	sha1 string
+}
+
+// NewLineage returns an empty Lineage ready to be set on a Pipeline.
+func NewLineage() *Lineage {
+	return &Lineage{
+		records:     make(map[string]*LineageRecord),
+		pending:     make(map[pendingDelivery][]string),
+		outstanding: make(map[string]int),
+	}
+}
+
+// NewStreamingLineage returns an empty Lineage that writes each LineageRecord
+// to w (as a line of JSON) once it's complete, i.e. once it's been received
+// by every DataProcessor it was sent to, rather than keeping it in memory.
+// Any records still incomplete when the Pipeline completes are written then.
+// Records, WriteJSON, and WriteOpenLineage only include records not yet
+// written, and payloads not yet forgotten (see Lineage).
+func NewStreamingLineage(w io.Writer) *Lineage {
+	l := NewLineage()
+	l.stream = w
+	return l
+}
+
+// Records returns a copy of every LineageRecord, in the order
+// the payloads were first seen.
+func (l *Lineage) Records() []LineageRecord {
+	l.Lock()
+	defer l.Unlock()
+	records := make([]LineageRecord, 0, len(l.order))
+	for _, id := range l.order {
+		r, ok := l.records[id]
+		if !ok {
+			continue
+		}
+		c := *r
+		c.DerivedFrom = append([]string(nil), r.DerivedFrom...)
+		c.ReceivedBy = append([]string(nil), r.ReceivedBy...)
+		records = append(records, c)
+	}
+	return records
+}
+
+// Err returns the first error writing records for a NewStreamingLineage.
+func (l *Lineage) Err() error {
+	l.Lock()
+	defer l.Unlock()
+	return l.streamErr
+}
+
+// WriteJSON writes every LineageRecord to w as a single JSON document.
+func (l *Lineage) WriteJSON(w io.Writer) error {
+	doc := struct {
+		Pipeline string          `json:"pipeline"`
+		Started  time.Time       `json:"started"`
+		Payloads []LineageRecord `json:"payloads"`
+	}{l.pipeline, l.started, l.Records()}
+	d, err := data.NewJSON(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(d)
+	return err
+}
+
+// WriteOpenLineage writes the lineage to w as a JSON array of OpenLineage
+// RunEvents (see https://openlineage.io), one COMPLETE event per DataProcessor.
+// Each event's job is named "<Pipeline.Name>.<processor>", and the payloads it
+// received and sent are listed as input and output datasets in the given namespace.
+func (l *Lineage) WriteOpenLineage(w io.Writer, namespace string) error {
+	type dataset struct {
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	}
+	type runEvent struct {
+		EventType string `json:"eventType"`
+		EventTime string `json:"eventTime"`
+		Run       struct {
+			RunID string `json:"runId"`
+		} `json:"run"`
+		Job       dataset   `json:"job"`
+		Inputs    []dataset `json:"inputs"`
+		Outputs   []dataset `json:"outputs"`
+		Producer  string    `json:"producer"`
+		SchemaURL string    `json:"schemaURL"`
+	}
+
+	records := l.Records()
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	l.Lock()
+	nodes := append([]*lineageNode(nil), l.processors...)
+	l.Unlock()
+
+	events := make([]runEvent, len(nodes))
+	for i, n := range nodes {
+		e := &events[i]
+		e.EventType = "COMPLETE"
+		e.EventTime = now
+		runID, err := newRunID()
+		if err != nil {
+			return err
+		}
+		e.Run.RunID = runID
+		e.Job = dataset{namespace, l.pipeline + "." + n.name}
+		e.Inputs = []dataset{}
+		e.Outputs = []dataset{}
+		for _, id := range n.receivedIDs() {
+			e.Inputs = append(e.Inputs, dataset{namespace, id})
+		}
+		for _, r := range records {
+			if r.Source == n.name {
+				e.Outputs = append(e.Outputs, dataset{namespace, r.ID})
+			}
+		}
+		e.Producer = "https://github.com/rhansen2/ratchet"
+		e.SchemaURL = "https://openlineage.io/spec/2-0-2/OpenLineage.json#/$defs/RunEvent"
+	}
+
+	d, err := data.NewJSON(events)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(d)
+	return err
+}
+
+// node registers a DataProcessor with the Lineage. Processors in the first
+// stage are sources: the data they receive (if any) is the StartSignal, not a payload.
+func (l *Lineage) node(name string, source bool) *lineageNode {
+	n := &lineageNode{lineage: l, name: name, source: source}
+	l.Lock()
+	l.processors = append(l.processors, n)
+	l.Unlock()
+	return n
+}
+
+func (l *Lineage) received(n *lineageNode, d data.JSON) string {
+	sum := payloadSHA1(d)
+	l.Lock()
+	defer l.Unlock()
+	key := pendingDelivery{n, sum}
+	ids := l.pending[key]
+	if len(ids) == 0 {
+		// Payloads sent outside of a DataProcessor's ProcessData or Finish
+		// calls can't be attributed, so they're recorded without a source.
+		r := l.add("", sum, d, nil)
+		r.ReceivedBy = []string{n.name}
+		l.complete(r)
+		return r.ID
+	}
+	id := ids[0]
+	if len(ids) == 1 {
+		delete(l.pending, key)
+	} else {
+		l.pending[key] = ids[1:]
+	}
+	r := l.records[id]
+	r.ReceivedBy = appendMissing(r.ReceivedBy, n.name)
+	l.outstanding[id]--
+	if l.outstanding[id] <= 0 {
+		delete(l.outstanding, id)
+		l.complete(r)
+	}
+	return id
+}
+
+func (l *Lineage) sent(n *lineageNode, d data.JSON, parents []string) {
+	sum := payloadSHA1(d)
+	l.Lock()
+	defer l.Unlock()
+	r := l.add(n.name, sum, d, parents)
+	if len(n.outputs) == 0 {
+		l.complete(r)
+		return
+	}
+	for _, to := range n.outputs {
+		key := pendingDelivery{to, sum}
+		l.pending[key] = append(l.pending[key], r.ID)
+	}
+	l.outstanding[r.ID] = len(n.outputs)
+}
+
+func (l *Lineage) add(source, sum string, d data.JSON, parents []string) *LineageRecord {
+	l.lastID++
+	r := &LineageRecord{
+		ID:          strconv.Itoa(l.lastID),
+		SHA1:        sum,
+		Source:      source,
+		DerivedFrom: append([]string(nil), parents...),
+		Bytes:       len(d),
+		Created:     time.Now(),
+	}
+	l.records[r.ID] = r
+	l.order = append(l.order, r.ID)
+	return r
+}
+
+// complete writes r to the stream, if streaming. It must be called with l locked.
+func (l *Lineage) complete(r *LineageRecord) {
+	if l.stream == nil {
+		return
+	}
+	delete(l.records, r.ID)
+	if len(l.order) > 2*len(l.records)+64 {
+		order := l.order[:0]
+		for _, id := range l.order {
+			if _, ok := l.records[id]; ok {
+				order = append(order, id)
+			}
+		}
+		l.order = order
+	}
+	if l.streamErr != nil {
+		return
+	}
+	d, err := data.NewJSON(r)
+	if err == nil {
+		_, err = l.stream.Write(append(d, '\n'))
+	}
+	l.streamErr = err
+}
+
+// flush writes any incomplete records to the stream, if streaming.
+func (l *Lineage) flush() {
+	l.Lock()
+	defer l.Unlock()
+	if l.stream == nil {
+		return
+	}
+	order := l.order
+	l.order = nil
+	for _, id := range order {
+		if r, ok := l.records[id]; ok {
+			l.complete(r)
+		}
+	}
+	l.pending = make(map[pendingDelivery][]string)
+	l.outstanding = make(map[string]int)
+}
+
+// lineageNode tracks a single DataProcessor within a Lineage. All
+// methods are safe to call on a nil *lineageNode (lineage disabled).
+type lineageNode struct {
+	lineage  *Lineage
+	name     string
+	source   bool
+	outputs  []*lineageNode
+	received []string
+	sync.Mutex
+}
+
+// receive records d as received and returns the parents
+// for any payloads sent while processing it.
+func (n *lineageNode) receive(d data.JSON) []string {
+	if n == nil || n.source {
+		return nil
+	}
+	id := n.lineage.received(n, d)
+	n.Lock()
+	n.received = append(n.received, id)
+	n.Unlock()
+	return []string{id}
+}
+
+func (n *lineageNode) send(d data.JSON, parents []string) {
+	if n == nil {
+		return
+	}
+	n.lineage.sent(n, d, parents)
+}
+
+// forget drops the given received payloads when streaming, once ProcessData
+// has sent payloads derived from them, so a long running Pipeline's memory
+// use doesn't grow with every payload received.
+func (n *lineageNode) forget(ids []string) {
+	if n == nil || n.lineage.stream == nil {
+		return
+	}
+	n.Lock()
+	defer n.Unlock()
+	for _, id := range ids {
+		// The payload is usually the most recently received.
+		for i := len(n.received) - 1; i >= 0; i-- {
+			if n.received[i] == id {
+				n.received = append(n.received[:i], n.received[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// receivedIDs returns every payload received so far, which are
+// the parents for any payloads sent from Finish.
+func (n *lineageNode) receivedIDs() []string {
+	if n == nil {
+		return nil
+	}
+	n.Lock()
+	defer n.Unlock()
+	return append([]string(nil), n.received...)
+}
+
+func payloadSHA1(d data.JSON) string {
+	sum := sha1.Sum(d)
+	return hex.EncodeToString(sum[:])
+}
+
+// newRunID returns a random (version 4) UUID.
+func newRunID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func appendMissing(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
@@ -0,0 +1,167 @@
+package ratchet_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+func TestLineageIdenticalPayloads(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	p := ratchet.NewPipeline(context.Background(), nil,
+		ratchettest.FeedJSON(`{"a":1}`, `{"a":1}`), processors.NewPassthrough(), ratchettest.NewCapture())
+	p.Lineage = ratchet.NewLineage()
+	if err := ratchettest.RunPipeline(t, p); err != nil {
+		t.Fatal(err)
+	}
+
+	records := p.Lineage.Records()
+	if len(records) != 4 {
+		t.Fatalf("got %d records, want 4: %+v", len(records), records)
+	}
+	ids := map[string]bool{}
+	for _, r := range records {
+		ids[r.ID] = true
+		if r.SHA1 != records[0].SHA1 {
+			t.Errorf("record %v has SHA1 %v, want %v", r.ID, r.SHA1, records[0].SHA1)
+		}
+	}
+	if len(ids) != 4 {
+		t.Errorf("got %d distinct IDs, want 4", len(ids))
+	}
+
+	var sent, passed []ratchet.LineageRecord
+	for _, r := range records {
+		switch r.Source {
+		case "1.1 Feeder":
+			sent = append(sent, r)
+		case "2.1 Passthrough":
+			passed = append(passed, r)
+		default:
+			t.Errorf("record %v has unexpected source %q", r.ID, r.Source)
+		}
+	}
+	if len(sent) != 2 || len(passed) != 2 {
+		t.Fatalf("got %d sent and %d passed records, want 2 each", len(sent), len(passed))
+	}
+	for i := range sent {
+		if len(sent[i].ReceivedBy) != 1 || sent[i].ReceivedBy[0] != "2.1 Passthrough" {
+			t.Errorf("record %v received by %v", sent[i].ID, sent[i].ReceivedBy)
+		}
+		if len(passed[i].DerivedFrom) != 1 || passed[i].DerivedFrom[0] != sent[i].ID {
+			t.Errorf("record %v derived from %v, want [%v]", passed[i].ID, passed[i].DerivedFrom, sent[i].ID)
+		}
+		if len(passed[i].ReceivedBy) != 1 || passed[i].ReceivedBy[0] != "3.1 Capture" {
+			t.Errorf("record %v received by %v", passed[i].ID, passed[i].ReceivedBy)
+		}
+	}
+}
+
+func TestStreamingLineage(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	var buf bytes.Buffer
+	p := ratchet.NewPipeline(context.Background(), nil,
+		ratchettest.FeedJSON(`1`, `2`, `3`), processors.NewPassthrough(), ratchettest.NewCapture())
+	p.Lineage = ratchet.NewStreamingLineage(&buf)
+	if err := ratchettest.RunPipeline(t, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Lineage.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if records := p.Lineage.Records(); len(records) != 0 {
+		t.Errorf("got %d records still in memory, want 0", len(records))
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 6 {
+		t.Fatalf("got %d records streamed, want 6:\n%s", len(lines), buf.Bytes())
+	}
+	for _, line := range lines {
+		var r ratchet.LineageRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatal(err)
+		}
+		if len(r.ReceivedBy) != 1 {
+			t.Errorf("record %+v was streamed before it was received", r)
+		}
+	}
+}
+
+// TestStreamingLineageForgets checks that a streaming Lineage only keeps the
+// payloads received by stages that haven't sent anything for them, which
+// payloads sent from Finish are derived from.
+func TestStreamingLineageForgets(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	for _, streaming := range []bool{false, true} {
+		p := ratchet.NewPipeline(context.Background(), nil,
+			ratchettest.FeedJSON(`1`, `2`, `3`), processors.NewPassthrough(),
+			concurrentPassthrough{processors.NewPassthrough()}, ratchettest.NewCapture())
+		p.Lineage = ratchet.NewLineage()
+		if streaming {
+			p.Lineage = ratchet.NewStreamingLineage(&bytes.Buffer{})
+		}
+		if err := ratchettest.RunPipeline(t, p); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := p.Lineage.WriteOpenLineage(&buf, "test"); err != nil {
+			t.Fatal(err)
+		}
+		var events []struct {
+			Job    struct{ Name string }
+			Inputs []struct{ Name string }
+		}
+		if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]int{
+			"Pipeline.1.1 Feeder":      0,
+			"Pipeline.2.1 Passthrough": 3,
+			"Pipeline.3.1 Passthrough": 3,
+			"Pipeline.4.1 Capture":     3,
+		}
+		if streaming {
+			want["Pipeline.2.1 Passthrough"] = 0
+			want["Pipeline.3.1 Passthrough"] = 0
+		}
+		for _, e := range events {
+			if len(e.Inputs) != want[e.Job.Name] {
+				t.Errorf("streaming %v: %v kept %d inputs, want %d", streaming, e.Job.Name, len(e.Inputs), want[e.Job.Name])
+			}
+		}
+		if len(events) != len(want) {
+			t.Errorf("got %d events, want %d", len(events), len(want))
+		}
+	}
+}
+
+func TestLineageSplitPayloads(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	capture := ratchettest.NewCapture()
+	p := ratchet.NewPipeline(context.Background(), nil, ratchettest.FeedJSON(`[1,2,3]`), capture)
+	p.Lineage = ratchet.NewLineage()
+	p.MaxPayloadSize = 5
+	p.PayloadSizePolicy = ratchet.SplitOversized
+	if err := ratchettest.RunPipeline(t, p); err != nil {
+		t.Fatal(err)
+	}
+	capture.AssertPayloads(t, `[1,2]`, `[3]`)
+
+	records := p.Lineage.Records()
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2: %+v", len(records), records)
+	}
+	for _, r := range records {
+		if r.Source != "1.1 Feeder" || len(r.ReceivedBy) != 1 {
+			t.Errorf("record %+v isn't attributed to the Feeder", r)
+		}
+	}
+}
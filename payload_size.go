@@ -0,0 +1,186 @@
+package ratchet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+)
+
+// PayloadSizePolicy controls what a Pipeline does with payloads larger than
+// Pipeline.MaxPayloadSize. The policy is applied to each payload as it's sent
+// by a DataProcessor, before it's passed on to the next stage (and before its
+// lineage is recorded, so each chunk of a split payload has its own record).
+//
+// Since payloads are limited once they've been sent, MaxPayloadSize protects
+// later stages from oversized payloads, but doesn't bound the memory used by
+// the DataProcessor building them. To avoid reading a large file into memory
+// at once, use a reader that sends it in parts, e.g. IoReader reading lines.
+type PayloadSizePolicy int
+
+const (
+	// RejectOversized drops oversized payloads, writing them to the
+	// Pipeline's DeadLetter if set, or otherwise sending an error to the
+	// sending DataProcessor's killChan (see ErrorPolicy). This is the default.
+	RejectOversized PayloadSizePolicy = iota
+	// TruncateOversized passes on as much of an oversized payload as fits.
+	// For a JSON array, that's the leading elements which fit, otherwise the
+	// payload is cut at MaxPayloadSize bytes (which is mostly useful for text,
+	// such as lines read by IoReader, as cut JSON is no longer valid).
+	TruncateOversized
+	// SplitOversized splits an oversized JSON array into chunks of elements,
+	// each no larger than MaxPayloadSize, and passes on every chunk. Oversized
+	// payloads that can't be split (non-arrays, or arrays with a single
+	// oversized element) are rejected as with RejectOversized.
+	SplitOversized
+)
+
+func (p PayloadSizePolicy) String() string {
+	switch p {
+	case RejectOversized:
+		return "reject"
+	case TruncateOversized:
+		return "truncate"
+	case SplitOversized:
+		return "split"
+	}
+	return fmt.Sprintf("PayloadSizePolicy(%d)", int(p))
+}
+
+// MarshalJSON encodes the policy as "reject", "truncate", or "split".
+func (p PayloadSizePolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON decodes a policy from "reject", "truncate", or "split".
+func (p *PayloadSizePolicy) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	switch s {
+	case "reject", "":
+		*p = RejectOversized
+	case "truncate":
+		*p = TruncateOversized
+	case "split":
+		*p = SplitOversized
+	default:
+		return fmt.Errorf("unknown payload size policy %q, must be reject, truncate, or split", s)
+	}
+	return nil
+}
+
+// DeadLetter is written (as a line of JSON) to the Pipeline's DeadLetter
+// for each payload rejected for being larger than MaxPayloadSize.
+type DeadLetter struct {
+	Stage     int             `json:"stage"`     // Starts at 1, matching Pipeline.Stats.
+	Processor string          `json:"processor"` // The sending DataProcessor, see PipelineErrors.Counts.
+	Size      int             `json:"size"`
+	Payload   json.RawMessage `json:"payload"` // Payloads that aren't valid JSON are encoded as a string.
+}
+
+// errNotSplittable is returned by splitJSONArray for payloads
+// that aren't a JSON array of elements under the size limit.
+var errNotSplittable = errors.New("payload is not a JSON array of elements under the size limit")
+
+// payloadLimiter applies a Pipeline's MaxPayloadSize and PayloadSizePolicy.
+type payloadLimiter struct {
+	max        int
+	policy     PayloadSizePolicy
+	deadLetter io.Writer
+	sync.Mutex // guards deadLetter
+}
+
+// limit returns the payloads to send in place of d, sent by dp.
+func (l *payloadLimiter) limit(d data.JSON, dp *dataProcessor) []data.JSON {
+	if l == nil || l.max <= 0 || len(d) <= l.max {
+		return []data.JSON{d}
+	}
+	switch l.policy {
+	case TruncateOversized:
+		logger.Error(dp, "payload of", len(d), "bytes truncated to", l.max)
+		if chunks, err := splitJSONArray(d, l.max, 1); err == nil {
+			return chunks
+		}
+		return []data.JSON{d[:l.max]}
+	case SplitOversized:
+		chunks, err := splitJSONArray(d, l.max, 0)
+		if err == nil {
+			logger.Info(dp, "payload of", len(d), "bytes split into", len(chunks))
+			return chunks
+		}
+	}
+	l.reject(d, dp)
+	return nil
+}
+
+func (l *payloadLimiter) reject(d data.JSON, dp *dataProcessor) {
+	err := fmt.Errorf("payload of %d bytes exceeds the maximum payload size of %d bytes", len(d), l.max)
+	if l.deadLetter == nil {
+		logger.Error(dp, err.Error())
+		select {
+		case dp.killChan <- err:
+		case <-dp.ctx.Done():
+		}
+		return
+	}
+
+	logger.Error(dp, err.Error(), "- writing to dead letter")
+	payload := json.RawMessage(d)
+	if !json.Valid(d) {
+		payload, _ = json.Marshal(string(d))
+	}
+	letter, err := json.Marshal(DeadLetter{Stage: dp.stage, Processor: dp.label, Size: len(d), Payload: payload})
+	if err == nil {
+		l.Lock()
+		_, err = l.deadLetter.Write(append(letter, '\n'))
+		l.Unlock()
+	}
+	if err != nil {
+		select {
+		case dp.killChan <- err:
+		case <-dp.ctx.Done():
+		}
+	}
+}
+
+// splitJSONArray splits a JSON array into arrays no larger than max bytes,
+// stopping after n arrays if n is positive.
+func splitJSONArray(d data.JSON, max int, n int) ([]data.JSON, error) {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return nil, errNotSplittable
+	}
+	chunks := []data.JSON{}
+	chunk := data.JSON("[")
+	for dec.More() {
+		var element json.RawMessage
+		if err := dec.Decode(&element); err != nil {
+			return nil, err
+		}
+		if len(element)+2 > max {
+			return nil, errNotSplittable
+		}
+		if len(chunk) > 1 && len(chunk)+len(element)+2 > max {
+			chunks = append(chunks, append(chunk, ']'))
+			if len(chunks) == n {
+				return chunks, nil
+			}
+			chunk = data.JSON("[")
+		}
+		if len(chunk) > 1 {
+			chunk = append(chunk, ',')
+		}
+		chunk = append(chunk, element...)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return append(chunks, append(chunk, ']')), nil
+}
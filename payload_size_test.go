@@ -0,0 +1,94 @@
+package ratchet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+)
+
+func TestSplitJSONArray(t *testing.T) {
+	tests := []struct {
+		d    string
+		max  int
+		n    int
+		want []string
+		err  bool
+	}{
+		{d: `[1,2,3]`, max: 5, want: []string{`[1,2]`, `[3]`}},
+		{d: `[1,2,3]`, max: 7, want: []string{`[1,2,3]`}},
+		{d: `[1,2,3]`, max: 3, want: []string{`[1]`, `[2]`, `[3]`}},
+		{d: `[1,2,3]`, max: 3, n: 1, want: []string{`[1]`}},
+		{d: `[1,2,3]`, max: 3, n: 2, want: []string{`[1]`, `[2]`}},
+		{d: ` [ {"a":"b"} , [1] ,"c" ] `, max: 12, want: []string{`[{"a":"b"}]`, `[[1],"c"]`}},
+		{d: `[]`, max: 2, want: []string{`[]`}},
+		{d: `[1,22,3]`, max: 3, err: true},
+		{d: `[1,2,3`, max: 5, err: true},
+		{d: `{"a":[1,2]}`, max: 5, err: true},
+		{d: `"[1,2]"`, max: 5, err: true},
+		{d: `not json`, max: 5, err: true},
+	}
+	for _, tt := range tests {
+		chunks, err := splitJSONArray(data.JSON(tt.d), tt.max, tt.n)
+		if tt.err {
+			if err == nil {
+				t.Errorf("splitJSONArray(%s, %d, %d): expected an error, got %q", tt.d, tt.max, tt.n, chunks)
+			}
+			continue
+		}
+		got := make([]string, len(chunks))
+		for i, c := range chunks {
+			got[i] = string(c)
+		}
+		if err != nil || strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("splitJSONArray(%s, %d, %d) = %q, %v, want %q", tt.d, tt.max, tt.n, got, err, tt.want)
+		}
+	}
+}
+
+func TestPayloadLimiter(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	tests := []struct {
+		policy     PayloadSizePolicy
+		d          string
+		want       []string
+		deadLetter string
+	}{
+		{policy: RejectOversized, d: `[1,2]`, want: []string{`[1,2]`}},
+		{policy: RejectOversized, d: `[1,2,3]`, deadLetter: `{"stage":2,"processor":"2.1 test","size":7,"payload":[1,2,3]}`},
+		{policy: RejectOversized, d: `1,2,3,4`, deadLetter: `{"stage":2,"processor":"2.1 test","size":7,"payload":"1,2,3,4"}`},
+		{policy: TruncateOversized, d: `[1,2,3]`, want: []string{`[1,2]`}},
+		{policy: TruncateOversized, d: `[123456]`, want: []string{`[1234`}},
+		{policy: SplitOversized, d: `[1,2,3]`, want: []string{`[1,2]`, `[3]`}},
+		{policy: SplitOversized, d: `{"a":1}`, deadLetter: `{"stage":2,"processor":"2.1 test","size":7,"payload":{"a":1}}`},
+	}
+	for _, tt := range tests {
+		var deadLetter bytes.Buffer
+		l := &payloadLimiter{max: 5, policy: tt.policy, deadLetter: &deadLetter}
+		dp := &dataProcessor{ctx: context.Background(), stage: 2, label: "2.1 test"}
+		var got []string
+		for _, d := range l.limit(data.JSON(tt.d), dp) {
+			got = append(got, string(d))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%v %s: got payloads %q, want %q", tt.policy, tt.d, got, tt.want)
+		}
+		if tt.deadLetter == "" {
+			if deadLetter.Len() != 0 {
+				t.Errorf("%v %s: got dead letter %s", tt.policy, tt.d, deadLetter.String())
+			}
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(deadLetter.Bytes(), &letter); err != nil {
+			t.Errorf("%v %s: %v", tt.policy, tt.d, err)
+		}
+		if strings.TrimSpace(deadLetter.String()) != tt.deadLetter {
+			t.Errorf("%v %s: got dead letter %s, want %s", tt.policy, tt.d, deadLetter.String(), tt.deadLetter)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package ratchet
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
+	"time"
 
 	"github.com/rhansen2/ratchet/data"
 	"github.com/rhansen2/ratchet/logger"
@@ -12,18 +14,30 @@ import (
 
 // StartSignal is what's sent to a starting DataProcessor
 // to kick off execution. Typically this value will be ignored.
+// Starting DataProcessors that implement Source are not sent
+// the StartSignal, instead their Start func is called.
 var StartSignal = "GO"
 
 // Pipeline is the main construct used for running a series of stages within a data pipeline.
 type Pipeline struct {
-	layout       *PipelineLayout
-	Name         string // Name is simply for display purpsoses in log output.
-	BufferLength int    // Set to control channel buffering, default is 8.
-	PrintData    bool   // Set to true to log full data payloads (only in Debug logging mode).
-	timer        *util.Timer
-	wg           sync.WaitGroup
-	ctx          context.Context
-	onComplete   func()
+	layout              *PipelineLayout
+	Name                string            // Name is simply for display purpsoses in log output.
+	BufferLength        int               // Set to control channel buffering, default is 8.
+	PrintData           bool              // Set to true to log full data payloads (only in Debug logging mode).
+	Lineage             *Lineage          // Set to NewLineage() to record the lineage of every payload.
+	ErrorPolicy         ErrorPolicy       // Set to CollectErrors to continue past errors, see ErrorPolicy.
+	MaxErrors           int               // Number of errors kept with CollectErrors, default is DefaultMaxErrors.
+	MaxPayloadSize      int               // Set to limit the size (in bytes) of payloads passed between stages.
+	PayloadSizePolicy   PayloadSizePolicy // What to do with payloads over MaxPayloadSize, default is RejectOversized.
+	DeadLetter          io.Writer         // Set to write payloads rejected for being oversized to, see DeadLetter.
+	ShutdownGracePeriod time.Duration     // How long Shutdown waits for stages to finish, default is DefaultShutdownGracePeriod.
+	shutdown            shutdown
+	errors              *errorCollector
+	errorsLock          sync.Mutex // Guards errors, which Errors and Stats can read while Run sets it.
+	timer               util.Timer
+	wg                  sync.WaitGroup
+	ctx                 context.Context
+	onComplete          func()
 }
 
 // NewPipeline creates a new pipeline ready to run the given DataProcessors.
@@ -77,7 +91,7 @@ func (p *Pipeline) dataProcessorOutputs(dp *dataProcessor) []*dataProcessor {
 // dataProcessor's outputs), we set up some intermediary channels that will
 // manage copying and passing data between stages, as well as properly closing
 // channels when all data is received.
-func (p *Pipeline) connectStages() {
+func (p *Pipeline) connectStages(killChan chan error) {
 	logger.Debug(p.Name, ": connecting stages")
 	// First, setup the bridgeing channels & brancher/merger's to aid in
 	// managing channel communication between processors.
@@ -96,11 +110,41 @@ func (p *Pipeline) connectStages() {
 			}
 		}
 	}
+	if p.Lineage != nil {
+		p.Lineage.pipeline = p.Name
+		p.Lineage.started = time.Now()
+	}
+	if p.Lineage != nil {
+		for n, stage := range p.layout.stages {
+			for j, dp := range stage.processors {
+				dp.lineage = p.Lineage.node(processorLabel(n, j, dp), n == 0)
+			}
+		}
+		for _, stage := range p.layout.stages {
+			for _, dp := range stage.processors {
+				for _, to := range p.dataProcessorOutputs(dp) {
+					dp.lineage.outputs = append(dp.lineage.outputs, to.lineage)
+				}
+			}
+		}
+	}
+	limiter := &payloadLimiter{max: p.MaxPayloadSize, policy: p.PayloadSizePolicy, deadLetter: p.DeadLetter}
 	// Loop through again and setup goroutines to handle data management
 	// between the branchers and mergers
-	for _, stage := range p.layout.stages {
-		for _, dp := range stage.processors {
+	for n, stage := range p.layout.stages {
+		for j, dp := range stage.processors {
 			dp.ctx = p.ctx
+			dp.processCtx = p.ctx
+			if n == 0 {
+				dp.processCtx = p.shutdown.sourceCtx
+			}
+			dp.stage = n + 1
+			dp.label = processorLabel(n, j, dp)
+			dp.limiter = limiter
+			dp.killChan = killChan
+			if p.errors != nil {
+				dp.killChan = p.errors.killChan(dp.stage, dp.label, dp)
+			}
 			if dp.branchOutChans != nil {
 				dp.branchOut()
 			}
@@ -111,11 +155,18 @@ func (p *Pipeline) connectStages() {
 	}
 }
 
-func (p *Pipeline) runStages(killChan chan error) {
+// processorLabel identifies the jth dataProcessor in stage n by position and name.
+func processorLabel(n, j int, dp *dataProcessor) string {
+	return fmt.Sprintf("%d.%d %v", n+1, j+1, dp)
+}
+
+func (p *Pipeline) runStages() {
 	for n, stage := range p.layout.stages {
 		for _, dp := range stage.processors {
+			source, isSource := dp.DataProcessor.(Source)
+			isSource = isSource && n == 0
 			numWorkers := 1
-			if dp.concurrency > 1 {
+			if dp.concurrency > 1 && !isSource {
 				numWorkers = dp.concurrency
 			}
 			p.wg.Add(numWorkers)
@@ -126,32 +177,42 @@ func (p *Pipeline) runStages(killChan chan error) {
 				go func(n int, dp *dataProcessor, i int) {
 					defer p.wg.Done()
 					defer concurrencyWg.Done()
+					if isSource {
+						logger.Info(p.Name, "- stage", n+1, dp, "starting")
+						dp.start(source, dp.killChan)
+						return
+					}
 					// This is where the main DataProcessor interface
 					// functions are called.
 					logger.Info(p.Name, "- stage", n+1, dp, "waiting to receive data")
-				processData:
 					for {
 						select {
 						case d, ok := <-dp.inputChan:
 							if !ok {
-								break processData
+								return
 							}
 							logger.Info(p.Name, "- stage", n+1, dp, "received data")
 							if p.PrintData {
 								logger.Debug(p.Name, "- stage", n+1, dp, "data =", string(d))
 							}
 							dp.recordDataReceived(d)
-							dp.processData(d, killChan)
+							dp.processData(d, dp.killChan)
 						case <-p.ctx.Done():
 							return
 						}
 					}
-					logger.Info(p.Name, "- stage", n+1, dp, "input closed, calling Finish")
-					dp.Finish(dp.outputChan, killChan, p.ctx)
 				}(n, dp, i)
 			}
+			// Once every worker is done, Finish is called (just once, even
+			// with multiple workers) and the output closed.
+			p.wg.Add(1)
 			go func(dp *dataProcessor, n int) {
+				defer p.wg.Done()
 				concurrencyWg.Wait()
+				if p.ctx.Err() == nil {
+					logger.Info(p.Name, "- stage", n+1, dp, "input closed, calling Finish")
+					dp.finish(dp.killChan)
+				}
 				if dp.outputChan != nil {
 					close(dp.outputChan)
 				}
@@ -166,34 +227,50 @@ func (p *Pipeline) runStages(killChan chan error) {
 // return prematurely. Any stage of the pipeline can send to the killChan to halt
 // execution. Your calling function should check if the sent value is an error or nil to know if
 // execution was a failure or a success (nil being the success value).
+// With the CollectErrors ErrorPolicy, a successful run with errors sends a *PipelineErrors.
 func (p *Pipeline) Run() (killChan chan error) {
-	p.timer = util.StartTimer()
+	p.timer.Start()
 	killChan = make(chan error)
 
 	innerKillChan := make(chan error)
-	p.connectStages()
-	p.runStages(innerKillChan)
+	p.initShutdown()
+	if p.ErrorPolicy == CollectErrors {
+		p.errorsLock.Lock()
+		p.errors = newErrorCollector(p.MaxErrors)
+		p.errorsLock.Unlock()
+	}
+	p.connectStages(innerKillChan)
+	p.runStages()
+	p.handleSignals()
 
+	// After all the stages are running, send the StartSignal
+	// to the initial stage processors that aren't Sources (which
+	// have already been started) to kick off execution. Closing their
+	// input then has their goroutines call Finish once the StartSignal
+	// has been processed.
 INIT:
 	for _, dp := range p.layout.stages[0].processors {
-		logger.Debug(p.Name, ": sending", StartSignal, "to", dp)
-		select {
-		case dp.inputChan <- data.JSON(StartSignal):
-		case <-p.ctx.Done():
-			break INIT
+		if _, ok := dp.DataProcessor.(Source); !ok {
+			logger.Debug(p.Name, ": sending", StartSignal, "to", dp)
+			select {
+			case dp.inputChan <- data.JSON(StartSignal):
+			case <-p.ctx.Done():
+				break INIT
+			}
 		}
-		dp.Finish(dp.outputChan, innerKillChan, p.ctx)
 		close(dp.inputChan)
 	}
 
-	// After all the stages are running, send the StartSignal
-	// to the initial stage processors to kick off execution, and
-	// then wait until all the processing goroutines are done to
+	// Wait until all the processing goroutines are done to
 	// signal successful pipeline completion.
 	donech := make(chan struct{})
+	var collected error
 	go func() {
 		p.wg.Wait()
 		p.timer.Stop()
+		if p.errors != nil {
+			collected = p.errors.stop()
+		}
 		close(donech)
 	}()
 
@@ -203,26 +280,75 @@ INIT:
 				p.onComplete()
 			}
 		}()
-		for {
-			select {
-			case err := <-innerKillChan:
-				killChan <- err
-				close(killChan)
-				return
-			case <-p.ctx.Done():
-				killChan <- p.ctx.Err()
-				close(killChan)
-				return
-			case <-donech:
-				killChan <- nil
-				close(killChan)
-				return
+		var err error
+		select {
+		case err = <-innerKillChan:
+			p.complete()
+		case <-p.ctx.Done():
+			err = p.ctx.Err()
+			if shutdownErr := p.complete(); shutdownErr == ErrShutdownTimeout {
+				err = shutdownErr
+			}
+		case <-donech:
+			err = p.complete()
+			if errs, ok := collected.(*PipelineErrors); ok && err != ErrShutdownTimeout {
+				errs.Shutdown = err == ErrShutdown
+				err = errs
 			}
+			if err != ErrShutdownTimeout {
+				if commitErr := p.commit(); commitErr != nil {
+					err = commitErr
+				}
+			}
+		}
+		if p.Lineage != nil {
+			p.Lineage.flush()
 		}
+		killChan <- err
+		close(killChan)
 	}()
 	return killChan
 }
 
+// commit calls Commit on every Committer, in stage order, returning the first
+// error. Later Committers are still called after an error.
+func (p *Pipeline) commit() error {
+	var first error
+	for _, stage := range p.layout.stages {
+		for _, dp := range stage.processors {
+			c, ok := dp.DataProcessor.(Committer)
+			if !ok {
+				continue
+			}
+			logger.Info(p.Name, "- stage", dp.stage, dp, "committing")
+			if err := c.Commit(); err != nil {
+				logger.Error(p.Name, "- stage", dp.stage, dp, "commit failed -", err.Error())
+				if first == nil {
+					first = err
+				}
+			}
+		}
+	}
+	return first
+}
+
+// Errors returns the errors collected so far when using the CollectErrors
+// ErrorPolicy, or nil otherwise.
+func (p *Pipeline) Errors() *PipelineErrors {
+	c := p.collector()
+	if c == nil {
+		return nil
+	}
+	return c.result()
+}
+
+// collector returns the errorCollector, if Run has set one up.
+func (p *Pipeline) collector() *errorCollector {
+	p.errorsLock.Lock()
+	defer p.errorsLock.Unlock()
+	return p.errors
+}
+
 func (p *Pipeline) Cleanup() {
 	if p.onComplete != nil {
 		p.onComplete()
@@ -250,18 +376,21 @@ func (p *Pipeline) initDataChan() chan data.JSON {
 // }
 
 // Stats returns a string (formatted for output display) listing the stats
-// gathered for each stage executed.
+// gathered for each stage executed. See StatsJSON for a machine-readable version.
 func (p *Pipeline) Stats() string {
-	o := fmt.Sprintf("%s: %s\r\n", p.Name, p.timer)
-	for n, stage := range p.layout.stages {
-		o += fmt.Sprintf("Stage %d)\r\n", n+1)
-		for _, dp := range stage.processors {
-			o += fmt.Sprintf("  * %v\r\n", dp)
-			dp.executionStat.calculate()
-			o += fmt.Sprintf("     - Total/Avg Execution Time = %f/%fs\r\n", dp.totalExecutionTime, dp.avgExecutionTime)
-			o += fmt.Sprintf("     - Payloads Sent/Received = %d/%d\r\n", dp.dataSentCounter, dp.dataReceivedCounter)
-			o += fmt.Sprintf("     - Total/Avg Bytes Sent = %d/%d\r\n", dp.totalBytesSent, dp.avgBytesSent)
-			o += fmt.Sprintf("     - Total/Avg Bytes Received = %d/%d\r\n", dp.totalBytesReceived, dp.avgBytesReceived)
+	collecting := p.collector() != nil
+	o := fmt.Sprintf("%s: %s\r\n", p.Name, &p.timer)
+	for _, stage := range p.StatsSnapshot().Stages {
+		o += fmt.Sprintf("Stage %d)\r\n", stage.Stage)
+		for _, s := range stage.Processors {
+			o += fmt.Sprintf("  * %v\r\n", s.Name)
+			o += fmt.Sprintf("     - Total/Avg Execution Time = %f/%fs\r\n", s.TotalExecutionSeconds, s.AvgExecutionSeconds)
+			o += fmt.Sprintf("     - Payloads Sent/Received = %d/%d\r\n", s.PayloadsSent, s.PayloadsReceived)
+			o += fmt.Sprintf("     - Total/Avg Bytes Sent = %d/%d\r\n", s.TotalBytesSent, s.AvgBytesSent)
+			o += fmt.Sprintf("     - Total/Avg Bytes Received = %d/%d\r\n", s.TotalBytesReceived, s.AvgBytesReceived)
+			if collecting {
+				o += fmt.Sprintf("     - Errors = %d\r\n", s.Errors)
+			}
 		}
 	}
 	return o
@@ -0,0 +1,71 @@
+package ratchet
+
+import (
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+)
+
+// PipelineStats is a snapshot of the stats gathered while running a Pipeline,
+// structured for logging to monitoring systems, storing per run, or comparing
+// across runs. See Pipeline.StatsSnapshot and Pipeline.StatsJSON.
+type PipelineStats struct {
+	Name    string       `json:"name"`
+	Started time.Time    `json:"started"`
+	Stopped *time.Time   `json:"stopped,omitempty"` // Nil while the pipeline is still running.
+	Seconds float64      `json:"seconds"`
+	Stages  []StageStats `json:"stages"`
+}
+
+// StageStats holds the stats for each DataProcessor in a PipelineStage.
+type StageStats struct {
+	Stage      int              `json:"stage"` // Starts at 1, matching Pipeline.Stats.
+	Processors []ProcessorStats `json:"processors"`
+}
+
+// ProcessorStats holds the stats gathered for a single DataProcessor.
+type ProcessorStats struct {
+	Name                  string  `json:"name"`
+	Concurrency           int     `json:"concurrency,omitempty"` // Zero unless it's a ConcurrentDataProcessor.
+	Executions            int     `json:"executions"`
+	TotalExecutionSeconds float64 `json:"total_execution_seconds"`
+	AvgExecutionSeconds   float64 `json:"avg_execution_seconds"`
+	PayloadsSent          int     `json:"payloads_sent"`
+	PayloadsReceived      int     `json:"payloads_received"`
+	TotalBytesSent        int     `json:"total_bytes_sent"`
+	AvgBytesSent          int     `json:"avg_bytes_sent"`
+	TotalBytesReceived    int     `json:"total_bytes_received"`
+	AvgBytesReceived      int     `json:"avg_bytes_received"`
+	Errors                int     `json:"errors"` // Only counted with the CollectErrors ErrorPolicy.
+}
+
+// StatsSnapshot returns the stats gathered for each stage executed.
+// It's safe to call while the pipeline is running.
+func (p *Pipeline) StatsSnapshot() *PipelineStats {
+	s := &PipelineStats{Name: p.Name}
+	if !p.timer.StartTime().IsZero() {
+		s.Started = p.timer.StartTime()
+		s.Seconds = p.timer.Duration().Seconds()
+		if p.timer.Stopped() {
+			stopped := p.timer.EndTime()
+			s.Stopped = &stopped
+		}
+	}
+	for n, stage := range p.layout.stages {
+		ss := StageStats{Stage: n + 1}
+		for _, dp := range stage.processors {
+			ps := dp.executionStat.stats()
+			ps.Name = dp.String()
+			ps.Concurrency = dp.concurrency
+			ss.Processors = append(ss.Processors, ps)
+		}
+		s.Stages = append(s.Stages, ss)
+	}
+	return s
+}
+
+// StatsJSON returns the stats gathered for each stage executed
+// as a JSON encoded PipelineStats.
+func (p *Pipeline) StatsJSON() (data.JSON, error) {
+	return data.NewJSON(p.StatsSnapshot())
+}
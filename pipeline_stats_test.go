@@ -0,0 +1,99 @@
+package ratchet_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+// failOdd sends an error for every other payload, passing the rest on.
+type failOdd struct {
+	n int
+}
+
+func (f *failOdd) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	f.n++
+	if f.n%2 == 1 {
+		killChan <- errors.New("odd payload")
+		return
+	}
+	outputChan <- d
+}
+
+func (f *failOdd) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {}
+
+func (f *failOdd) String() string {
+	return "failOdd"
+}
+
+type concurrentPassthrough struct {
+	*processors.Passthrough
+}
+
+func (p concurrentPassthrough) Concurrency() int {
+	return 4
+}
+
+// TestStatsJSONWhileRunning is meant to be run with -race.
+func TestStatsJSONWhileRunning(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	payloads := make([]string, 200)
+	for i := range payloads {
+		payloads[i] = `{"a":1}`
+	}
+	p := ratchet.NewPipeline(context.Background(), nil,
+		ratchettest.FeedJSON(payloads...), &failOdd{}, concurrentPassthrough{processors.NewPassthrough()}, ratchettest.NewCapture())
+	p.ErrorPolicy = ratchet.CollectErrors
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			if _, err := p.StatsJSON(); err != nil {
+				t.Error(err)
+				return
+			}
+			p.Stats()
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	}()
+	err := ratchettest.RunPipeline(t, p)
+	close(stop)
+	wg.Wait()
+	if _, ok := err.(*ratchet.PipelineErrors); !ok {
+		t.Fatalf("got error %v, want *PipelineErrors", err)
+	}
+
+	d, err := p.StatsJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats ratchet.PipelineStats
+	if err := json.Unmarshal(d, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Stopped == nil {
+		t.Error("pipeline isn't stopped")
+	}
+	failed := stats.Stages[1].Processors[0]
+	if failed.PayloadsReceived != 200 || failed.PayloadsSent != 100 || failed.Errors != 100 {
+		t.Errorf("got stats %+v", failed)
+	}
+	if passed := stats.Stages[2].Processors[0]; passed.PayloadsSent != 100 || passed.Concurrency != 4 {
+		t.Errorf("got stats %+v", passed)
+	}
+}
@@ -1,6 +1,7 @@
 package ratchet_test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -17,7 +18,7 @@ func ExampleNewPipeline() {
 	// A basic pipeline is created using one or more DataProcessor instances.
 	hello := processors.NewIoReader(strings.NewReader("Hello world!"))
 	stdout := processors.NewIoWriter(os.Stdout)
-	pipeline := ratchet.NewPipeline(hello, stdout)
+	pipeline := ratchet.NewPipeline(context.Background(), nil, hello, stdout)
 
 	err := <-pipeline.Run()
 
@@ -75,7 +76,7 @@ func ExampleNewBranchingPipeline() {
 	}
 
 	// Create and run the Pipeline
-	pipeline := ratchet.NewBranchingPipeline(layout)
+	pipeline := ratchet.NewBranchingPipeline(context.Background(), nil, layout)
 	err = <-pipeline.Run()
 
 	if err != nil {
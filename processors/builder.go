@@ -0,0 +1,163 @@
+package processors
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// resources opens the files, databases, and connection pools used by the
+// built-in processors.
+type resources interface {
+	// reader opens the file at path for reading, where "-" means stdin.
+	reader(path string) (io.Reader, error)
+	// writer creates the file at path for writing, where "-" means stdout.
+	writer(path string) (io.Writer, error)
+	db(driver, dsn string) (*sql.DB, error)
+	ledger(path string) (util.Ledger, error)
+	checkpoint(path string) (util.Checkpoint, error)
+	redisPool(url string) *redis.Pool
+}
+
+// Builder constructs registered DataProcessors like New, but keeps track of
+// the files, databases, and Redis connection pools opened by the built-in
+// processors, so they can be closed once the Pipeline has completed. For example:
+//
+//	b := &processors.Builder{}
+//	defer b.Close()
+//	layout, err := config.Layout(b.New)
+type Builder struct {
+	closers []io.Closer
+	sync.Mutex
+}
+
+// New constructs the DataProcessor registered under name, see New.
+func (b *Builder) New(name string, params map[string]interface{}) (ratchet.DataProcessor, error) {
+	r, ok := lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("processors: unknown processor %q", name)
+	}
+	if params == nil {
+		params = Params{}
+	}
+	if r.builtin == nil {
+		return r.factory(params)
+	}
+	return r.builtin(params, b)
+}
+
+// Close closes everything opened by the DataProcessors constructed so far,
+// returning the first error.
+func (b *Builder) Close() error {
+	b.Lock()
+	closers := b.closers
+	b.closers = nil
+	b.Unlock()
+	var err error
+	for _, c := range closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (b *Builder) track(c io.Closer) {
+	b.Lock()
+	b.closers = append(b.closers, c)
+	b.Unlock()
+}
+
+func (b *Builder) reader(path string) (io.Reader, error) {
+	if path == "-" {
+		return os.Stdin, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	b.track(f)
+	return f, nil
+}
+
+func (b *Builder) writer(path string) (io.Writer, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	b.track(f)
+	return f, nil
+}
+
+func (b *Builder) db(driver, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	b.track(db)
+	return db, nil
+}
+
+func (b *Builder) ledger(path string) (util.Ledger, error) {
+	l, err := util.NewFileLedger(path)
+	if err != nil {
+		return nil, err
+	}
+	b.track(l)
+	return l, nil
+}
+
+func (b *Builder) checkpoint(path string) (util.Checkpoint, error) {
+	return util.NewFileCheckpoint(path)
+}
+
+func (b *Builder) redisPool(url string) *redis.Pool {
+	pool := newRedisPool(url)
+	b.track(pool)
+	return pool
+}
+
+// checkResources stands in for the files and databases
+// used by the built-in processors, see Validate.
+type checkResources struct{}
+
+func (checkResources) reader(path string) (io.Reader, error) {
+	return strings.NewReader(""), nil
+}
+
+func (checkResources) writer(path string) (io.Writer, error) {
+	return ioutil.Discard, nil
+}
+
+func (checkResources) db(driver, dsn string) (*sql.DB, error) {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("sql: unknown driver %q (forgotten import?)", driver)
+}
+
+func (checkResources) ledger(path string) (util.Ledger, error) {
+	return util.NewMemoryLedger(), nil
+}
+
+func (checkResources) checkpoint(path string) (util.Checkpoint, error) {
+	return util.NewMemoryCheckpoint(), nil
+}
+
+// redisPool returns a pool that's never used, so never connects.
+func (checkResources) redisPool(url string) *redis.Pool {
+	return newRedisPool(url)
+}
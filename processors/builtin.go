@@ -0,0 +1,870 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/util"
+	"golang.org/x/crypto/ssh"
+)
+
+// The built-in processors are registered here. FuncTransformer is not
+// registered, since it can only be constructed with a Go func.
+func init() {
+	registerBuiltin("csv_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Filename string `json:"filename"`
+		}
+		if err := decodeParams(p, &c, "filename"); err != nil {
+			return nil, err
+		}
+		return configureCSVReader(NewCSVReader(c.Filename), p)
+	})
+	registerBuiltin("csv_transformer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		t := NewCSVTransformer()
+		if err := configureCSVParameters(&t.Parameters, p); err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+	registerBuiltin("csv_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Path string `json:"path"`
+		}
+		if err := decodeParams(p, &c, "path"); err != nil {
+			return nil, err
+		}
+		w, err := res.writer(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		csvWriter := NewCSVWriter(w)
+		if err := configureCSVParameters(&csvWriter.Parameters, p); err != nil {
+			return nil, err
+		}
+		return csvWriter, nil
+	})
+	registerBuiltin("dir_watcher", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Dir string `json:"dir"`
+		}
+		if err := decodeParams(p, &c, "dir"); err != nil {
+			return nil, err
+		}
+		return configureDirWatcher(NewDirWatcher(c.Dir), p, res)
+	})
+	registerBuiltin("enricher", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			sqlParams
+			KeyField  string     `json:"key_field"`
+			Query     string     `json:"query"`
+			Preload   bool       `json:"preload"`
+			KeyColumn string     `json:"key_column"`
+			File      string     `json:"file"`
+			RedisURL  string     `json:"redis_url"`
+			KeyPrefix string     `json:"key_prefix"`
+			Hash      bool       `json:"hash"`
+			URL       string     `json:"url"`
+			Fields    []string   `json:"fields"`
+			Into      string     `json:"into"`
+			Miss      MissPolicy `json:"miss"`
+			CacheSize int        `json:"cache_size"`
+			TTL       string     `json:"ttl"`
+		}
+		if err := decodeParams(p, &c, "key_field"); err != nil {
+			return nil, err
+		}
+		var backend LookupBackend
+		switch {
+		case c.Query != "":
+			if err := p.Require("driver", "dsn"); err != nil {
+				return nil, err
+			}
+			db, err := res.db(c.Driver, c.DSN)
+			if err != nil {
+				return nil, err
+			}
+			if c.Preload {
+				// Every row is loaded into memory on the first
+				// payload, rather than queried per key.
+				if err := p.Require("key_column"); err != nil {
+					return nil, err
+				}
+				backend = NewSQLMemoryLookup(db, c.Query, c.KeyColumn)
+			} else {
+				backend = NewSQLLookup(db, c.Query)
+			}
+		case c.File != "":
+			r, err := res.reader(c.File)
+			if err != nil {
+				return nil, err
+			}
+			d, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			keyField := c.KeyColumn
+			if keyField == "" {
+				keyField = c.KeyField
+			}
+			backend = NewJSONMemoryLookup(d, keyField)
+		case c.RedisURL != "":
+			r := NewRedisLookupByPool(res.redisPool(c.RedisURL))
+			r.KeyPrefix = c.KeyPrefix
+			r.Hash = c.Hash
+			backend = r
+		case c.URL != "":
+			backend = NewHTTPLookup(c.URL)
+		default:
+			return nil, errors.New(`enricher requires one of a "query", "file", "redis_url", or "url" param`)
+		}
+		e := NewEnricher(backend, c.KeyField)
+		e.Fields = c.Fields
+		e.Into = c.Into
+		e.Miss = c.Miss
+		if c.CacheSize != 0 {
+			e.CacheSize = c.CacheSize
+		}
+		if c.TTL != "" {
+			var err error
+			if e.TTL, err = time.ParseDuration(c.TTL); err != nil {
+				return nil, err
+			}
+		}
+		return e, nil
+	})
+	registerBuiltin("file_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Filename string `json:"filename"`
+		}
+		if err := decodeParams(p, &c, "filename"); err != nil {
+			return nil, err
+		}
+		return NewFileReader(c.Filename), nil
+	})
+	registerBuiltin("fixed_width_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Filename  string                `json:"filename"`
+			Layout    util.FixedWidthLayout `json:"layout"`
+			NullValue string                `json:"null_value"`
+			SkipLines int                   `json:"skip_lines"`
+		}
+		if err := decodeParams(p, &c, "layout"); err != nil {
+			return nil, err
+		}
+		r, err := NewFixedWidthReader(c.Filename, c.Layout)
+		if err != nil {
+			return nil, err
+		}
+		r.NullValue = c.NullValue
+		r.SkipLines = c.SkipLines
+		return r, nil
+	})
+	registerBuiltin("fixed_width_transformer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Layout util.FixedWidthLayout `json:"layout"`
+		}
+		if err := decodeParams(p, &c, "layout"); err != nil {
+			return nil, err
+		}
+		t, err := NewFixedWidthTransformer(c.Layout)
+		if err != nil {
+			return nil, err
+		}
+		if err := configureCSVParameters(&t.Parameters, p); err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+	registerBuiltin("fixed_width_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Path   string                `json:"path"`
+			Layout util.FixedWidthLayout `json:"layout"`
+		}
+		if err := decodeParams(p, &c, "path", "layout"); err != nil {
+			return nil, err
+		}
+		if err := c.Layout.Validate(); err != nil {
+			return nil, err
+		}
+		w, err := res.writer(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		fixedWidthWriter, err := NewFixedWidthWriter(w, c.Layout)
+		if err != nil {
+			return nil, err
+		}
+		if err := configureCSVParameters(&fixedWidthWriter.Parameters, p); err != nil {
+			return nil, err
+		}
+		return fixedWidthWriter, nil
+	})
+	registerBuiltin("ftp_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Host     string `json:"host"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Path     string `json:"path"`
+		}
+		if err := decodeParams(p, &c, "host", "path"); err != nil {
+			return nil, err
+		}
+		return NewFtpWriter(c.Host, c.Username, c.Password, c.Path), nil
+	})
+	registerBuiltin("html_table_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Selector string   `json:"selector"`
+			Header   []string `json:"header"`
+		}
+		if err := decodeParams(p, &c, "selector"); err != nil {
+			return nil, err
+		}
+		r := NewHTMLTableReader(c.Selector)
+		r.Header = c.Header
+		return r, nil
+	})
+	registerBuiltin("http_request", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		c := struct {
+			Method string `json:"method"`
+			URL    string `json:"url"`
+			Body   string `json:"body"`
+		}{Method: "GET"}
+		if err := decodeParams(p, &c, "url"); err != nil {
+			return nil, err
+		}
+		var body io.Reader
+		if c.Body != "" {
+			body = strings.NewReader(c.Body)
+		}
+		return NewHTTPRequest(c.Method, c.URL, body)
+	})
+	registerBuiltin("io_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		c := struct {
+			Path       string `json:"path"`
+			LineByLine bool   `json:"line_by_line"`
+			BufferSize int    `json:"buffer_size"`
+			Gzipped    bool   `json:"gzipped"`
+		}{LineByLine: true, BufferSize: 1024}
+		if err := decodeParams(p, &c, "path"); err != nil {
+			return nil, err
+		}
+		r, err := res.reader(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		ir := NewIoReader(r)
+		ir.LineByLine = c.LineByLine
+		ir.BufferSize = c.BufferSize
+		ir.Gzipped = c.Gzipped
+		return ir, nil
+	})
+	registerBuiltin("io_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Path       string `json:"path"`
+			AddNewline bool   `json:"add_newline"`
+		}
+		if err := decodeParams(p, &c, "path"); err != nil {
+			return nil, err
+		}
+		w, err := res.writer(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		iw := NewIoWriter(w)
+		iw.AddNewline = c.AddNewline
+		return iw, nil
+	})
+	registerBuiltin("ldap_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			URL              string   `json:"url"`
+			BaseDN           string   `json:"base_dn"`
+			Filter           string   `json:"filter"`
+			Attributes       []string `json:"attributes"`
+			BindDN           string   `json:"bind_dn"`
+			BindPassword     string   `json:"bind_password"`
+			StartTLS         bool     `json:"start_tls"`
+			Timeout          string   `json:"timeout"`
+			Scope            string   `json:"scope"`
+			SizeLimit        int      `json:"size_limit"`
+			PageSize         *int     `json:"page_size"`
+			MultiValued      []string `json:"multi_valued"`
+			BinaryAttributes []string `json:"binary_attributes"`
+		}
+		if err := decodeParams(p, &c, "url", "base_dn"); err != nil {
+			return nil, err
+		}
+		r := NewLDAPReader(c.URL, c.BaseDN, c.Filter, c.Attributes...)
+		r.BindDN = c.BindDN
+		r.BindPassword = c.BindPassword
+		r.StartTLS = c.StartTLS
+		r.SizeLimit = c.SizeLimit
+		r.MultiValued = c.MultiValued
+		r.BinaryAttributes = c.BinaryAttributes
+		if c.PageSize != nil {
+			r.PageSize = *c.PageSize
+		}
+		switch c.Scope {
+		case "sub", "":
+			r.Scope = ldap.ScopeWholeSubtree
+		case "one":
+			r.Scope = ldap.ScopeSingleLevel
+		case "base":
+			r.Scope = ldap.ScopeBaseObject
+		default:
+			return nil, fmt.Errorf("ldap_reader: unknown scope %q, must be sub, one, or base", c.Scope)
+		}
+		if c.Timeout != "" {
+			var err error
+			if r.Timeout, err = time.ParseDuration(c.Timeout); err != nil {
+				return nil, err
+			}
+		}
+		return r, nil
+	})
+	registerBuiltin("passthrough", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		return NewPassthrough(), nil
+	})
+	registerBuiltin("recorder", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Path     string `json:"path"`
+			Compress bool   `json:"compress"`
+		}
+		if err := decodeParams(p, &c, "path"); err != nil {
+			return nil, err
+		}
+		w, err := res.writer(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		r := NewRecorder(w)
+		r.Compress = c.Compress
+		return r, nil
+	})
+	registerBuiltin("redshift_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			sqlParams
+			s3Params
+			Table       string `json:"table"`
+			IAMRole     string `json:"iam_role"`
+			BatchSize   int    `json:"batch_size"`
+			CreateTable bool   `json:"create_table"`
+		}
+		if err := decodeParams(p, &c, "driver", "dsn", "table", "region", "bucket"); err != nil {
+			return nil, err
+		}
+		db, err := res.db(c.Driver, c.DSN)
+		if err != nil {
+			return nil, err
+		}
+		w := NewRedshiftWriter(db, c.Table, c.AwsID, c.AwsSecret, c.Region, c.Bucket)
+		w.Prefix = c.Prefix
+		w.IAMRole = c.IAMRole
+		w.BatchSize = c.BatchSize
+		w.CreateTable = c.CreateTable
+		return w, nil
+	})
+	registerBuiltin("regexp_matcher", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Pattern string `json:"pattern"`
+		}
+		if err := decodeParams(p, &c, "pattern"); err != nil {
+			return nil, err
+		}
+		return NewRegexpMatcher(c.Pattern), nil
+	})
+	registerBuiltin("replayer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Path  string  `json:"path"`
+			Speed float64 `json:"speed"`
+		}
+		if err := decodeParams(p, &c, "path"); err != nil {
+			return nil, err
+		}
+		r, err := res.reader(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		replayer := NewReplayer(r)
+		replayer.Speed = c.Speed
+		return replayer, nil
+	})
+	registerBuiltin("rss_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			URL      string `json:"url"`
+			Interval string `json:"interval"`
+			Ledger   string `json:"ledger"`
+			Once     bool   `json:"once"`
+		}
+		if err := decodeParams(p, &c, "url"); err != nil {
+			return nil, err
+		}
+		r := NewRSSReader(c.URL)
+		r.Once = c.Once
+		var err error
+		if c.Interval != "" {
+			if r.Interval, err = time.ParseDuration(c.Interval); err != nil {
+				return nil, err
+			}
+		}
+		if c.Ledger != "" {
+			if r.Ledger, err = res.ledger(c.Ledger); err != nil {
+				return nil, err
+			}
+		}
+		return r, nil
+	})
+	registerBuiltin("s3_dir_watcher", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c s3Params
+		if err := decodeParams(p, &c, "region", "bucket", "prefix"); err != nil {
+			return nil, err
+		}
+		return configureDirWatcher(NewS3DirWatcher(c.AwsID, c.AwsSecret, c.Region, c.Bucket, c.Prefix), p, res)
+	})
+	registerBuiltin("s3_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			s3Params
+			Object        string `json:"object"`
+			DeleteObjects bool   `json:"delete_objects"`
+		}
+		if err := decodeParams(p, &c, "region", "bucket"); err != nil {
+			return nil, err
+		}
+		var r *S3Reader
+		switch {
+		case c.Object != "":
+			r = NewS3ObjectReader(c.AwsID, c.AwsSecret, c.Region, c.Bucket, c.Object)
+		case c.Prefix != "":
+			r = NewS3PrefixReader(c.AwsID, c.AwsSecret, c.Region, c.Bucket, c.Prefix)
+		default:
+			return nil, errors.New(`s3_reader requires either an "object" or "prefix" param`)
+		}
+		r.DeleteObjects = c.DeleteObjects
+		return r, configureChunkedTransfer(&r.ChunkedTransfer, p, res)
+	})
+	registerBuiltin("s3_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		c := struct {
+			s3Params
+			Key           string `json:"key"`
+			Compress      bool   `json:"compress"`
+			LineSeparator string `json:"line_separator"`
+		}{LineSeparator: "\n"}
+		if err := decodeParams(p, &c, "region", "bucket", "key"); err != nil {
+			return nil, err
+		}
+		w := NewS3Writer(c.AwsID, c.AwsSecret, c.Region, c.Bucket, c.Key)
+		w.Compress = c.Compress
+		w.LineSeparator = c.LineSeparator
+		return w, nil
+	})
+	registerBuiltin("scp", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			Object      string `json:"object"`
+			Destination string `json:"destination"`
+			Port        string `json:"port"`
+		}
+		if err := decodeParams(p, &c, "object", "destination"); err != nil {
+			return nil, err
+		}
+		s := NewSCP(c.Object, c.Destination)
+		s.Port = c.Port
+		return s, nil
+	})
+	registerBuiltin("sftp_dir_watcher", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c sftpParams
+		if err := decodeParams(p, &c, "server", "username", "path"); err != nil {
+			return nil, err
+		}
+		auth, err := c.authMethods()
+		if err != nil {
+			return nil, err
+		}
+		options, err := c.options()
+		if err != nil {
+			return nil, err
+		}
+		w := NewSftpDirWatcher(c.Server, c.Username, c.Path, auth...)
+		w.SetSftpOptions(options)
+		return configureDirWatcher(w, p, res)
+	})
+	registerBuiltin("sftp_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			sftpParams
+			Walk          bool `json:"walk"`
+			FileNamesOnly bool `json:"file_names_only"`
+			DeleteObjects bool `json:"delete_objects"`
+		}
+		if err := decodeParams(p, &c, "server", "username", "path"); err != nil {
+			return nil, err
+		}
+		auth, err := c.authMethods()
+		if err != nil {
+			return nil, err
+		}
+		r := NewSftpReader(c.Server, c.Username, c.Path, auth...)
+		if r.SftpOptions, err = c.options(); err != nil {
+			return nil, err
+		}
+		r.Walk = c.Walk
+		r.FileNamesOnly = c.FileNamesOnly
+		r.DeleteObjects = c.DeleteObjects
+		return r, configureChunkedTransfer(&r.ChunkedTransfer, p, res)
+	})
+	registerBuiltin("sftp_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c sftpParams
+		if err := decodeParams(p, &c, "server", "username", "path"); err != nil {
+			return nil, err
+		}
+		auth, err := c.authMethods()
+		if err != nil {
+			return nil, err
+		}
+		w := NewSftpWriter(c.Server, c.Username, c.Path, auth...)
+		if w.SftpOptions, err = c.options(); err != nil {
+			return nil, err
+		}
+		return w, nil
+	})
+	registerBuiltin("snowflake_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		c := struct {
+			sqlParams
+			s3Params
+			Table       string `json:"table"`
+			Stage       string `json:"stage"`
+			BatchSize   int    `json:"batch_size"`
+			CreateTable bool   `json:"create_table"`
+			Parquet     bool   `json:"parquet"`
+		}{Stage: "@~"}
+		if err := decodeParams(p, &c, "driver", "dsn", "table"); err != nil {
+			return nil, err
+		}
+		db, err := res.db(c.Driver, c.DSN)
+		if err != nil {
+			return nil, err
+		}
+		var w *SnowflakeWriter
+		if c.Bucket != "" {
+			w = NewSnowflakeS3Writer(db, c.Table, c.Stage, c.AwsID, c.AwsSecret, c.Region, c.Bucket, c.Prefix)
+		} else {
+			w = NewSnowflakeWriter(db, c.Table, c.Stage)
+		}
+		w.BatchSize = c.BatchSize
+		w.CreateTable = c.CreateTable
+		w.Parquet = c.Parquet
+		return w, nil
+	})
+	registerBuiltin("sql_executor", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			sqlParams
+			Query string `json:"query"`
+		}
+		if err := decodeParams(p, &c, "driver", "dsn", "query"); err != nil {
+			return nil, err
+		}
+		db, err := res.db(c.Driver, c.DSN)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLExecutor(db, c.Query), nil
+	})
+	registerBuiltin("sql_reader", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			sqlParams
+			Query       string `json:"query"`
+			BatchSize   int    `json:"batch_size"`
+			Concurrency int    `json:"concurrency"`
+		}
+		if err := decodeParams(p, &c, "driver", "dsn", "query"); err != nil {
+			return nil, err
+		}
+		db, err := res.db(c.Driver, c.DSN)
+		if err != nil {
+			return nil, err
+		}
+		r := NewSQLReader(db, c.Query)
+		if c.BatchSize > 0 {
+			r.BatchSize = c.BatchSize
+		}
+		r.ConcurrencyLevel = c.Concurrency
+		return r, nil
+	})
+	registerBuiltin("sql_reader_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		var c struct {
+			ReadDriver  string `json:"read_driver"`
+			ReadDSN     string `json:"read_dsn"`
+			WriteDriver string `json:"write_driver"`
+			WriteDSN    string `json:"write_dsn"`
+			Query       string `json:"query"`
+			Table       string `json:"table"`
+			Concurrency int    `json:"concurrency"`
+		}
+		if err := decodeParams(p, &c, "read_driver", "read_dsn", "write_driver", "write_dsn", "query", "table"); err != nil {
+			return nil, err
+		}
+		readDB, err := res.db(c.ReadDriver, c.ReadDSN)
+		if err != nil {
+			return nil, err
+		}
+		writeDB, err := res.db(c.WriteDriver, c.WriteDSN)
+		if err != nil {
+			return nil, err
+		}
+		s := NewSQLReaderWriter(readDB, writeDB, c.Query, c.Table)
+		s.ConcurrencyLevel = c.Concurrency
+		return s, nil
+	})
+	registerBuiltin("sql_writer", func(p Params, res resources) (ratchet.DataProcessor, error) {
+		c := struct {
+			sqlParams
+			Table          string   `json:"table"`
+			OnDupKeyUpdate bool     `json:"on_dup_key_update"`
+			OnDupKeyFields []string `json:"on_dup_key_fields"`
+			BatchSize      int      `json:"batch_size"`
+			Concurrency    int      `json:"concurrency"`
+		}{OnDupKeyUpdate: true}
+		if err := decodeParams(p, &c, "driver", "dsn", "table"); err != nil {
+			return nil, err
+		}
+		db, err := res.db(c.Driver, c.DSN)
+		if err != nil {
+			return nil, err
+		}
+		w := NewSQLWriter(db, c.Table)
+		w.OnDupKeyUpdate = c.OnDupKeyUpdate
+		w.OnDupKeyFields = c.OnDupKeyFields
+		w.BatchSize = c.BatchSize
+		w.ConcurrencyLevel = c.Concurrency
+		return w, nil
+	})
+}
+
+// decodeParams checks the required params are present and then decodes them into v.
+func decodeParams(p Params, v interface{}, required ...string) error {
+	if err := p.Require(required...); err != nil {
+		return err
+	}
+	return p.Decode(v)
+}
+
+type s3Params struct {
+	AwsID     string `json:"aws_id"`
+	AwsSecret string `json:"aws_secret"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+}
+
+type sqlParams struct {
+	Driver string `json:"driver"`
+	DSN    string `json:"dsn"`
+}
+
+type sftpParams struct {
+	Server        string `json:"server"`
+	Username      string `json:"username"`
+	Path          string `json:"path"`
+	Password      string `json:"password"`
+	KeyFile       string `json:"key_file"`
+	KnownHosts    string `json:"known_hosts"`
+	HostKey       string `json:"host_key"`
+	InsecureHost  bool   `json:"insecure_ignore_host_key"`
+	Timeout       string `json:"timeout"`
+	ReadTimeout   string `json:"read_timeout"`
+	KeepAlive     string `json:"keep_alive"`
+	MaxReconnects int    `json:"max_reconnects"`
+}
+
+// options returns the host key verification and connection options. The host
+// key is checked against host_key, known_hosts, or ~/.ssh/known_hosts (in that
+// order), unless insecure_ignore_host_key is set.
+func (c *sftpParams) options() (util.SftpOptions, error) {
+	o := util.SftpOptions{MaxReconnects: c.MaxReconnects}
+	var err error
+	switch {
+	case c.HostKey != "":
+		o.HostKeyCallback, err = util.SftpPinnedHostKey(c.HostKey)
+	case c.KnownHosts != "":
+		o.HostKeyCallback, err = util.SftpKnownHosts(c.KnownHosts)
+	default:
+		o.InsecureIgnoreHostKey = c.InsecureHost
+	}
+	if err != nil {
+		return o, err
+	}
+	for _, d := range []struct {
+		param string
+		value *time.Duration
+	}{{c.Timeout, &o.Timeout}, {c.ReadTimeout, &o.ReadTimeout}, {c.KeepAlive, &o.KeepAlive}} {
+		if d.param == "" {
+			continue
+		}
+		if *d.value, err = time.ParseDuration(d.param); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+func (c *sftpParams) authMethods() ([]ssh.AuthMethod, error) {
+	auth := []ssh.AuthMethod{}
+	if c.KeyFile != "" {
+		a, err := util.SftpKeyAuth(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, a)
+	}
+	if c.Password != "" {
+		auth = append(auth, ssh.Password(c.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New(`sftp requires a "password" or "key_file" param`)
+	}
+	return auth, nil
+}
+
+// configureDirWatcher applies the options shared by all DirWatcher params.
+func configureDirWatcher(w *DirWatcher, p Params, res resources) (ratchet.DataProcessor, error) {
+	var c struct {
+		Interval      string `json:"interval"`
+		SettleTime    string `json:"settle_time"`
+		Ledger        string `json:"ledger"`
+		FileNamesOnly bool   `json:"file_names_only"`
+		DeleteObjects bool   `json:"delete_objects"`
+	}
+	if err := p.Decode(&c); err != nil {
+		return nil, err
+	}
+	var err error
+	if c.Interval != "" {
+		if w.Interval, err = time.ParseDuration(c.Interval); err != nil {
+			return nil, err
+		}
+	}
+	if c.SettleTime != "" {
+		if w.SettleTime, err = time.ParseDuration(c.SettleTime); err != nil {
+			return nil, err
+		}
+	}
+	if c.Ledger != "" {
+		if w.Ledger, err = res.ledger(c.Ledger); err != nil {
+			return nil, err
+		}
+	}
+	w.FileNamesOnly = c.FileNamesOnly
+	w.DeleteObjects = c.DeleteObjects
+	return w, nil
+}
+
+// configureCSVReader applies the delimited format params to a CSVReader.
+func configureCSVReader(r *CSVReader, p Params) (ratchet.DataProcessor, error) {
+	var c struct {
+		Comma     string `json:"comma"`
+		Quote     string `json:"quote"`
+		Escape    string `json:"escape"`
+		NullValue string `json:"null_value"`
+	}
+	if err := p.Decode(&c); err != nil {
+		return nil, err
+	}
+	var err error
+	if r.Comma, err = paramRune(c.Comma, r.Comma); err != nil {
+		return nil, err
+	}
+	if r.Quote, err = paramRune(c.Quote, r.Quote); err != nil {
+		return nil, err
+	}
+	if r.Escape, err = paramRune(c.Escape, r.Quote); err != nil {
+		return nil, err
+	}
+	r.NullValue = c.NullValue
+	return r, nil
+}
+
+// configureCSVParameters applies the output format params shared by CSV and fixed-width writers.
+func configureCSVParameters(params *util.CSVParameters, p Params) error {
+	var c struct {
+		Comma             string   `json:"comma"`
+		Quote             string   `json:"quote"`
+		QuoteEscape       *string  `json:"quote_escape"`
+		AlwaysEncapsulate *bool    `json:"always_encapsulate"`
+		WriteHeader       *bool    `json:"write_header"`
+		Header            []string `json:"header"`
+		NullValue         string   `json:"null_value"`
+	}
+	if err := p.Decode(&c); err != nil {
+		return err
+	}
+	w := params.Writer
+	var err error
+	if w.Comma, err = paramRune(c.Comma, w.Comma); err != nil {
+		return err
+	}
+	if w.Quote, err = paramRune(c.Quote, w.Quote); err != nil {
+		return err
+	}
+	if c.QuoteEscape != nil {
+		w.QuoteEscape = *c.QuoteEscape
+	}
+	if c.AlwaysEncapsulate != nil {
+		w.AlwaysEncapsulate = *c.AlwaysEncapsulate
+	}
+	if c.WriteHeader != nil {
+		params.WriteHeader = *c.WriteHeader
+	}
+	if c.Header != nil {
+		params.Header = c.Header
+	}
+	params.NullValue = c.NullValue
+	return nil
+}
+
+// paramRune parses a single character param, such as a delimiter. An empty
+// param returns def, and `\t` may be used for a tab.
+func paramRune(s string, def rune) (rune, error) {
+	if s == "" {
+		return def, nil
+	}
+	if s == `\t` {
+		return '\t', nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("%q must be a single character", s)
+	}
+	return runes[0], nil
+}
+
+// configureChunkedTransfer applies the ChunkedTransfer params shared by remote readers.
+func configureChunkedTransfer(t *ChunkedTransfer, p Params, res resources) error {
+	var c struct {
+		ChunkSize      int64  `json:"chunk_size"`
+		Checkpoint     string `json:"checkpoint"`
+		ResumeFrom     int64  `json:"resume_from"`
+		Checksum       string `json:"checksum"`
+		VerifyChecksum bool   `json:"verify_checksum"`
+		SendMetadata   bool   `json:"send_metadata"`
+	}
+	if err := p.Decode(&c); err != nil {
+		return err
+	}
+	if c.Checkpoint != "" {
+		checkpoint, err := res.checkpoint(c.Checkpoint)
+		if err != nil {
+			return err
+		}
+		t.Checkpoint = checkpoint
+	}
+	t.ChunkSize = c.ChunkSize
+	t.ResumeFrom = c.ResumeFrom
+	t.Checksum = c.Checksum
+	t.VerifyChecksum = c.VerifyChecksum
+	t.SendMetadata = c.SendMetadata
+	return nil
+}
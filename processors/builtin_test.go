@@ -0,0 +1,147 @@
+package processors_test
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+func TestLookup(t *testing.T) {
+	if _, ok := processors.Lookup("sftp_reader"); !ok {
+		t.Error(`Lookup("sftp_reader") found nothing`)
+	}
+	if _, ok := processors.Lookup("sftpreader"); ok {
+		t.Error(`Lookup("sftpreader") found a Factory`)
+	}
+	names := processors.Registered()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("Registered() isn't sorted: %v", names)
+	}
+	for _, name := range names {
+		if _, ok := processors.Lookup(name); !ok {
+			t.Errorf("Lookup(%q) found nothing", name)
+		}
+	}
+}
+
+func TestBuiltinParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params processors.Params
+		err    string
+	}{
+		{"passthrough", nil, ""},
+		{"nope", nil, `unknown processor "nope"`},
+		{"regexp_matcher", processors.Params{}, `missing required param "pattern"`},
+		{"regexp_matcher", processors.Params{"pattern": 5}, "cannot unmarshal number"},
+		{"regexp_matcher", processors.Params{"pattern": "ERROR"}, ""},
+		{"csv_transformer", processors.Params{"comma": `\t`}, ""},
+		{"csv_transformer", processors.Params{"comma": "||"}, "must be a single character"},
+		{"csv_transformer", processors.Params{"write_header": "no"}, "cannot unmarshal string"},
+		{"http_request", processors.Params{"url": "http://example.com", "method": "POST", "body": "{}"}, ""},
+		{"http_request", processors.Params{"method": "GET"}, `missing required param "url"`},
+		{"fixed_width_transformer", processors.Params{"layout": "a,b"}, "cannot unmarshal string"},
+		{"fixed_width_transformer", processors.Params{"layout": []map[string]interface{}{{"name": "a", "start": 0, "width": 0}}}, "width of 0"},
+		{"fixed_width_reader", processors.Params{"layout": []map[string]interface{}{{"name": "a", "start": 0, "width": 2}}}, ""},
+		{"ldap_reader", processors.Params{"url": "ldap://localhost", "base_dn": "dc=example", "scope": "one", "timeout": "5s"}, ""},
+		{"ldap_reader", processors.Params{"url": "ldap://localhost"}, `missing required param "base_dn"`},
+		{"ldap_reader", processors.Params{"url": "ldap://localhost", "base_dn": "dc=example", "scope": "all"}, "unknown scope"},
+		{"ldap_reader", processors.Params{"url": "ldap://localhost", "base_dn": "dc=example", "timeout": "soon"}, "invalid duration"},
+		{"ldap_reader", processors.Params{"url": "ldap://localhost", "base_dn": "dc=example", "page_size": "all"}, "cannot unmarshal string"},
+		{"enricher", processors.Params{"key_field": "id"}, `requires one of`},
+		{"enricher", processors.Params{"key_field": "id", "url": "http://example.com/{key}", "miss": "ignore"}, "unknown miss policy"},
+		{"enricher", processors.Params{"key_field": "id", "url": "http://example.com/{key}", "ttl": "1h"}, ""},
+		{"enricher", processors.Params{"key_field": "id", "query": "SELECT 1"}, `missing required param "driver"`},
+		{"s3_reader", processors.Params{"region": "us-east-1", "bucket": "b"}, `requires either an "object" or "prefix"`},
+		{"s3_writer", processors.Params{"region": "us-east-1", "bucket": "b"}, `missing required param "key"`},
+		{"sftp_reader", processors.Params{"server": "localhost:22", "username": "u", "path": "/"}, `requires a "password" or "key_file"`},
+		{"sftp_reader", processors.Params{"server": "localhost:22", "username": "u", "path": "/", "password": "p", "timeout": 30}, "cannot unmarshal number"},
+		{"sql_writer", processors.Params{"driver": "postgres", "table": "t"}, `missing required param "dsn"`},
+		{"sql_writer", processors.Params{"driver": "postgres", "dsn": "x", "table": "t", "batch_size": "big"}, "cannot unmarshal string"},
+	}
+	for _, test := range tests {
+		p, err := processors.New(test.name, test.params)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("New(%q, %v) returned error %v", test.name, test.params, err)
+			} else if p == nil {
+				t.Errorf("New(%q, %v) returned nil", test.name, test.params)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("New(%q, %v) returned error %v, want %q", test.name, test.params, err, test.err)
+		}
+	}
+}
+
+func TestBuiltinParamCoercion(t *testing.T) {
+	p, err := processors.New("csv_transformer", processors.Params{"comma": `\t`, "header": []string{"b", "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := p.(*processors.CSVTransformer)
+	if c.Parameters.Writer.Comma != '\t' {
+		t.Errorf("comma is %q, want a tab", c.Parameters.Writer.Comma)
+	}
+	if strings.Join(c.Parameters.Header, ",") != "b,a" {
+		t.Errorf("header is %v", c.Parameters.Header)
+	}
+
+	p, err = processors.New("ldap_reader", processors.Params{"url": "ldap://localhost", "base_dn": "dc=example", "page_size": 0, "timeout": "5s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := p.(*processors.LDAPReader)
+	if r.PageSize != 0 || r.Timeout != 5*time.Second {
+		t.Errorf("page size is %v and timeout %v, want 0 and 5s", r.PageSize, r.Timeout)
+	}
+
+	p, err = processors.New("enricher", processors.Params{"key_field": "id", "url": "http://example.com/{key}", "miss": "drop", "ttl": "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := p.(*processors.Enricher)
+	if e.Miss != processors.MissDrop || e.TTL != time.Hour || e.CacheSize != 10000 {
+		t.Errorf("miss is %v, TTL %v, and cache size %v", e.Miss, e.TTL, e.CacheSize)
+	}
+}
+
+// TestValidateOpensNothing checks Validate doesn't read the files that New would.
+func TestValidateOpensNothing(t *testing.T) {
+	tests := []struct {
+		name   string
+		params processors.Params
+	}{
+		{"enricher", processors.Params{"key_field": "id", "file": "does-not-exist.json"}},
+		// Reading a directory as a checkpoint file fails.
+		{"sftp_reader", processors.Params{"server": "localhost:22", "username": "u", "path": "/", "password": "p", "checkpoint": os.TempDir()}},
+	}
+	for _, test := range tests {
+		if err := processors.Validate(test.name, test.params); err != nil {
+			t.Errorf("Validate(%q, %v) returned error %v", test.name, test.params, err)
+		}
+		if _, err := processors.New(test.name, test.params); err == nil {
+			t.Errorf("New(%q, %v) didn't fail", test.name, test.params)
+		}
+	}
+}
+
+func TestBuilderClosesRedisPool(t *testing.T) {
+	b := &processors.Builder{}
+	p, err := b.New("enricher", processors.Params{"key_field": "id", "redis_url": "redis://localhost:6379/0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := ratchettest.Process(t, p, data.JSON(`{"id":1}`))
+	r.AssertError(t, "closed pool")
+}
@@ -0,0 +1,210 @@
+package processors
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// ChunkedTransfer configures how large remote objects are transferred by the
+// readers that embed it (SftpReader, S3Reader, and GCSReader).
+//
+// By default each object is read using the reader's embedded IoReader.
+// Setting ChunkSize instead sends each object as raw chunks of up to ChunkSize
+// bytes, recording the byte offset reached in Checkpoint after each chunk is
+// sent. If a transfer is interrupted, the next run resumes from the recorded
+// offset rather than starting over. Offsets are recorded against the object's
+// path, size, and version (its modification time for sftp, its ETag for S3,
+// or its generation for Google Cloud Storage), so an object that has since changed is transferred from the start, and
+// each offset is cleared from Checkpoint once its object has been transferred.
+// When reading a single object (not a directory or prefix), ResumeFrom can be
+// set to start from a specific byte offset when no offset has been recorded.
+//
+// Setting Checksum to "md5" or "sha256" computes a checksum of each object as
+// it's transferred (including any bytes skipped when resuming). If
+// VerifyChecksum is also set, the checksum is compared to the one published by
+// the server: the ETag for S3 (md5 only), the object's md5 for Google Cloud
+// Storage, or a "<path>.md5"/"<path>.sha256" file alongside the object for sftp. A mismatch halts the pipeline, and the
+// object's offset is left in Checkpoint.
+// If SendMetadata is set, a TransferMetadata payload is sent after each
+// object's data.
+type ChunkedTransfer struct {
+	ChunkSize      int64
+	Checkpoint     util.Checkpoint
+	ResumeFrom     int64
+	Checksum       string
+	VerifyChecksum bool
+	SendMetadata   bool
+}
+
+// TransferMetadata describes an object transferred by a ChunkedTransfer.
+type TransferMetadata struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	ResumedFrom int64  `json:"resumed_from,omitempty"`
+	MD5         string `json:"md5,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+}
+
+// remoteObject is a single object to be transferred.
+type remoteObject struct {
+	path string
+	size int64
+	// version changes whenever the object is modified.
+	version string
+	// resumeFrom is the offset to start from when none is recorded.
+	resumeFrom int64
+	// reader is only needed when sending chunks.
+	reader io.ReaderAt
+	// serverChecksum returns the checksum published by the server
+	// for the given algorithm, for use with VerifyChecksum.
+	serverChecksum func(algorithm string) (string, error)
+}
+
+func (t *ChunkedTransfer) chunked() bool {
+	return t.ChunkSize > 0
+}
+
+// newHash returns the hash for the configured Checksum, or nil if none is set.
+func (t *ChunkedTransfer) newHash() (hash.Hash, error) {
+	switch t.Checksum {
+	case "":
+		return nil, nil
+	case "md5":
+		return md5.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum %q, must be md5 or sha256", t.Checksum)
+	}
+}
+
+// hashReader wraps r so the configured checksum is computed while
+// the object is read by IoReader. The returned hash may be nil.
+func (t *ChunkedTransfer) hashReader(r io.Reader) (io.Reader, hash.Hash, error) {
+	h, err := t.newHash()
+	if err != nil || h == nil {
+		return r, nil, err
+	}
+	return io.TeeReader(r, h), h, nil
+}
+
+// checkpointName is the name obj's offset is recorded under.
+func (obj *remoteObject) checkpointName() string {
+	return fmt.Sprintf("%v@%v@%v", obj.path, obj.size, obj.version)
+}
+
+// sendChunks sends obj on outputChan in chunks, resuming from any recorded
+// offset, and then calls complete. If ctx is done first, it returns ctx.Err().
+func (t *ChunkedTransfer) sendChunks(obj *remoteObject, outputChan chan data.JSON, ctx context.Context) error {
+	name := obj.checkpointName()
+	offset := obj.resumeFrom
+	if t.Checkpoint != nil {
+		recorded, err := t.Checkpoint.Offset(name)
+		if err != nil {
+			return err
+		}
+		if recorded > 0 {
+			offset = recorded
+		}
+	}
+	if offset > obj.size {
+		offset = obj.size
+	}
+	resumedFrom := offset
+	if offset > 0 {
+		logger.Info("ChunkedTransfer: resuming", obj.path, "from byte", offset, "of", obj.size)
+	}
+
+	h, err := t.newHash()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, t.ChunkSize)
+	if h != nil && offset > 0 {
+		// The checksum covers the whole object, so the bytes sent
+		// before resuming need to be read (but not sent) again.
+		if _, err := io.CopyBuffer(h, io.NewSectionReader(obj.reader, 0, offset), buf); err != nil {
+			return err
+		}
+	}
+
+	r := io.NewSectionReader(obj.reader, offset, obj.size-offset)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		chunk := make(data.JSON, n)
+		copy(chunk, buf[:n])
+		if h != nil {
+			h.Write(chunk)
+		}
+		select {
+		case outputChan <- chunk:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		offset += int64(n)
+		logger.Debug("ChunkedTransfer:", obj.path, "sent through byte", offset, "of", obj.size)
+		if t.Checkpoint != nil {
+			if err := t.Checkpoint.SetOffset(name, offset); err != nil {
+				return err
+			}
+		}
+	}
+	if err := t.complete(obj, h, resumedFrom, outputChan, ctx); err != nil {
+		return err
+	}
+	if t.Checkpoint != nil {
+		return t.Checkpoint.Clear(name)
+	}
+	return nil
+}
+
+// complete verifies the object's checksum and sends its TransferMetadata, if
+// configured. If ctx is done before the metadata is sent, it returns ctx.Err().
+func (t *ChunkedTransfer) complete(obj *remoteObject, h hash.Hash, resumedFrom int64, outputChan chan data.JSON, ctx context.Context) error {
+	meta := TransferMetadata{Path: obj.path, Size: obj.size, ResumedFrom: resumedFrom}
+	if h != nil {
+		sum := hex.EncodeToString(h.Sum(nil))
+		if t.Checksum == "md5" {
+			meta.MD5 = sum
+		} else {
+			meta.SHA256 = sum
+		}
+		logger.Info("ChunkedTransfer:", obj.path, t.Checksum, "=", sum)
+		if t.VerifyChecksum {
+			expected, err := obj.serverChecksum(t.Checksum)
+			if err != nil {
+				return err
+			}
+			if expected != sum {
+				return fmt.Errorf("ChunkedTransfer: %v checksum mismatch for %v: got %v, expected %v", t.Checksum, obj.path, sum, expected)
+			}
+		}
+	}
+	if t.SendMetadata {
+		d, err := data.NewJSON(meta)
+		if err != nil {
+			return err
+		}
+		select {
+		case outputChan <- d:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/util"
+)
+
+const chunkedContents = "0123456789"
+
+func newRemoteObject(version string, checksum string) *remoteObject {
+	return &remoteObject{
+		path:    "data.txt",
+		size:    int64(len(chunkedContents)),
+		version: version,
+		reader:  bytes.NewReader([]byte(chunkedContents)),
+		serverChecksum: func(string) (string, error) {
+			return checksum, nil
+		},
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendAll runs sendChunks to completion, returning what it sent.
+func sendAll(t *testing.T, c *ChunkedTransfer, obj *remoteObject) ([]string, error) {
+	t.Helper()
+	outputChan := make(chan data.JSON, 100)
+	err := c.sendChunks(obj, outputChan, context.Background())
+	close(outputChan)
+	sent := []string{}
+	for d := range outputChan {
+		sent = append(sent, string(d))
+	}
+	return sent, err
+}
+
+func TestSendChunks(t *testing.T) {
+	checkpoint := util.NewMemoryCheckpoint()
+	c := &ChunkedTransfer{ChunkSize: 4, Checkpoint: checkpoint}
+	obj := newRemoteObject("v1", "")
+	sent, err := sendAll(t, c, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sent, "|") != "0123|4567|89" {
+		t.Errorf("sent %q", sent)
+	}
+	if offset, _ := checkpoint.Offset(obj.checkpointName()); offset != 0 {
+		t.Errorf("offset %d left in checkpoint after completing", offset)
+	}
+}
+
+func TestSendChunksResume(t *testing.T) {
+	checkpoint := util.NewMemoryCheckpoint()
+	c := &ChunkedTransfer{ChunkSize: 4, Checkpoint: checkpoint, Checksum: "md5", VerifyChecksum: true, SendMetadata: true}
+	obj := newRemoteObject("v1", md5Hex(chunkedContents))
+	checkpoint.SetOffset(obj.checkpointName(), 6)
+
+	sent, err := sendAll(t, c, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[0] != "6789" {
+		t.Fatalf("sent %q, want the rest of the object and its metadata", sent)
+	}
+	var meta TransferMetadata
+	if err := json.Unmarshal([]byte(sent[1]), &meta); err != nil {
+		t.Fatal(err)
+	}
+	want := TransferMetadata{Path: "data.txt", Size: 10, ResumedFrom: 6, MD5: md5Hex(chunkedContents)}
+	if meta != want {
+		t.Errorf("sent metadata %+v, want %+v", meta, want)
+	}
+}
+
+func TestSendChunksChangedObject(t *testing.T) {
+	checkpoint := util.NewMemoryCheckpoint()
+	c := &ChunkedTransfer{ChunkSize: 4, Checkpoint: checkpoint}
+	checkpoint.SetOffset(newRemoteObject("v1", "").checkpointName(), 6)
+
+	// The offset was recorded for an earlier version, so it's ignored.
+	sent, err := sendAll(t, c, newRemoteObject("v2", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sent, "") != chunkedContents {
+		t.Errorf("sent %q, want the whole object", sent)
+	}
+}
+
+func TestSendChunksResumeFrom(t *testing.T) {
+	checkpoint := util.NewMemoryCheckpoint()
+	c := &ChunkedTransfer{ChunkSize: 4, Checkpoint: checkpoint}
+	obj := newRemoteObject("v1", "")
+	obj.resumeFrom = 8
+	sent, err := sendAll(t, c, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sent, "|") != "89" {
+		t.Errorf("sent %q", sent)
+	}
+
+	// A recorded offset takes precedence.
+	checkpoint.SetOffset(obj.checkpointName(), 4)
+	sent, err = sendAll(t, c, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sent, "|") != "4567|89" {
+		t.Errorf("sent %q", sent)
+	}
+}
+
+func TestSendChunksChecksumMismatch(t *testing.T) {
+	checkpoint := util.NewMemoryCheckpoint()
+	c := &ChunkedTransfer{ChunkSize: 4, Checkpoint: checkpoint, Checksum: "md5", VerifyChecksum: true}
+	obj := newRemoteObject("v1", md5Hex("something else"))
+	_, err := sendAll(t, c, obj)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("got error %v, want a checksum mismatch", err)
+	}
+	if offset, _ := checkpoint.Offset(obj.checkpointName()); offset != obj.size {
+		t.Errorf("offset %d in checkpoint, want %d", offset, obj.size)
+	}
+
+	c.Checksum = "sha1"
+	if _, err := sendAll(t, c, obj); err == nil {
+		t.Error("no error for an unsupported checksum")
+	}
+}
+
+func TestSendChunksCancelled(t *testing.T) {
+	checkpoint := util.NewMemoryCheckpoint()
+	c := &ChunkedTransfer{ChunkSize: 4, Checkpoint: checkpoint}
+	obj := newRemoteObject("v1", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	outputChan := make(chan data.JSON)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- c.sendChunks(obj, outputChan, ctx)
+	}()
+	<-outputChan
+	cancel()
+	if err := <-errChan; err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	if offset, _ := checkpoint.Offset(obj.checkpointName()); offset != 4 {
+		t.Errorf("offset %d in checkpoint, want 4", offset)
+	}
+}
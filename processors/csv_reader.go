@@ -2,31 +2,66 @@ package processors
 
 import (
 	"context"
-	"encoding/csv"
+	"fmt"
 	"os"
 
 	"github.com/rhansen2/ratchet/data"
 	"github.com/rhansen2/ratchet/util"
 )
 
-// CSVReader reads csv content from a file
+// CSVReader reads csv content from a file.
+//
+// Comma, Quote and Escape can be changed to read other delimited
+// formats, e.g. pipe or tab separated files, or files that escape quotes
+// with a backslash. If NullValue is set, fields equal to it (e.g. "NULL"
+// or `\N`) are sent as null rather than as a string.
+//
+// CSVReader is a ratchet.Source, so it's typically used in the first stage
+// of a Pipeline. In later stages, it reads the file again for every payload received.
 type CSVReader struct {
-	filename string
+	filename  string
+	Comma     rune
+	Quote     rune
+	Escape    rune
+	NullValue string
 }
 
 // NewCSVReader creates a CSVReader that will read the file
 // as csv data and send it line by line
 func NewCSVReader(filename string) *CSVReader {
-	return &CSVReader{filename: filename}
+	return &CSVReader{filename: filename, Comma: ',', Quote: '"', Escape: '"'}
 }
 
+// NewDelimitedReader creates a CSVReader that will read the file as
+// fields separated by comma, e.g. '|' or '\t'.
+func NewDelimitedReader(filename string, comma rune) *CSVReader {
+	c := NewCSVReader(filename)
+	c.Comma = comma
+	return c
+}
+
+// ProcessData calls Start, ignoring the data received.
 func (c *CSVReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	c.Start(outputChan, killChan, ctx)
+}
+
+// Start reads the file, sending all of its rows as a single slice of objects.
+func (c *CSVReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	f, err := os.Open(c.filename)
-	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
 	defer f.Close()
-	reader := csv.NewReader(f)
+	reader := util.NewDelimitedReader(f)
+	reader.Comma = c.Comma
+	reader.Quote = c.Quote
+	reader.Escape = c.Escape
 	csvs, err := reader.ReadAll()
-	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
 	if len(csvs) < 2 {
 		return
 	}
@@ -34,13 +69,24 @@ func (c *CSVReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan
 	headers := csvs[0]
 	for i := 1; i < len(csvs); i++ {
 		currObj := make(map[string]interface{})
+		if len(csvs[i]) != len(headers) {
+			util.KillPipelineIfErr(fmt.Errorf("CSVReader: line %d has %d fields, expected %d", i+1, len(csvs[i]), len(headers)), killChan, ctx)
+			return
+		}
 		for j, header := range headers {
-			currObj[header] = csvs[i][j]
+			if c.NullValue != "" && csvs[i][j] == c.NullValue {
+				currObj[header] = nil
+			} else {
+				currObj[header] = csvs[i][j]
+			}
 		}
 		res[i-1] = currObj
 	}
 	jd, err := data.NewJSON(res)
-	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
 	select {
 	case outputChan <- jd:
 	case <-ctx.Done():
@@ -0,0 +1,481 @@
+package processors
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/sftp"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+	"golang.org/x/crypto/ssh"
+)
+
+// DirWatcher watches a directory and sends each new file downstream as it
+// arrives, which turns a Pipeline into a long-running file-drop ingestion
+// daemon. DirWatcher is a ratchet.Source, so it should be used in the first
+// stage of a Pipeline, and will keep running until the Pipeline's context is
+// cancelled (e.g. by Pipeline.Shutdown).
+//
+// Use NewDirWatcher to watch a local directory (using fsnotify, backed by
+// polling), or NewSftpDirWatcher/NewS3DirWatcher to poll a remote directory
+// or prefix on an interval. Hidden files (names starting with ".") and
+// subdirectories are ignored.
+//
+// Delivery is at-least-once. DirWatcher is a ratchet.Committer: files are
+// only recorded in the Ledger (and deleted, if DeleteObjects is set) once the
+// Pipeline has finished with them, i.e. when it completes or is shut down
+// within its grace period. Until then they're only remembered in memory, so
+// if the process dies, or the Pipeline is halted by an error, every file sent
+// since the Pipeline started is sent again after a restart. Set Ledger to a
+// util.NewFileLedger to remember processed files across restarts.
+//
+// A file that can't be opened or read is logged and tried again on the next
+// scan, rather than halting the Pipeline. Some of its data may already have
+// been sent, and is sent again.
+//
+// DirWatcher embeds an IoReader, so it supports the same configuration
+// options as IoReader for reading file contents. To only send file details
+// (see WatchedFile) and not file contents, set FileNamesOnly to true.
+type DirWatcher struct {
+	IoReader      // embeds IoReader
+	lister        dirLister
+	Interval      time.Duration // How often to look for new files, default is 30s.
+	SettleTime    time.Duration // How long a file must go unmodified before it's sent, default is 5s.
+	Ledger        util.Ledger   // Defaults to an in-memory util.MemoryLedger.
+	FileNamesOnly bool
+	DeleteObjects bool
+	pending       []pendingFile // Sent, but not yet committed.
+	sent          map[string]bool
+	sync.Mutex
+}
+
+// pendingFile is a file that's been sent, keyed as it's recorded in the Ledger.
+type pendingFile struct {
+	key  string
+	path string
+}
+
+// WatchedFile is sent by DirWatcher in place of the file
+// contents when FileNamesOnly is set to true.
+type WatchedFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// dirLister abstracts the different places a DirWatcher can watch.
+type dirLister interface {
+	list() ([]WatchedFile, error)
+	open(path string) (io.ReadCloser, error)
+	remove(path string) error
+	// watch returns a channel that receives when the directory changes, or
+	// nil if changes can only be found by polling.
+	watch(ctx context.Context) (<-chan struct{}, error)
+	close()
+}
+
+// NewDirWatcher returns a new DirWatcher for the given local directory.
+func NewDirWatcher(dir string) *DirWatcher {
+	return newDirWatcher(&localLister{dir: dir})
+}
+
+// NewSftpDirWatcher returns a new DirWatcher that polls the given directory on
+// a remote sftp server. A connection to the remote server is delayed until the
+// first poll. A dropped connection is re-established up to MaxReconnects times
+// (see SetSftpOptions), and otherwise on the next poll.
+func NewSftpDirWatcher(server string, username string, path string, authMethods ...ssh.AuthMethod) *DirWatcher {
+	parameters := &util.SftpParameters{
+		Server:      server,
+		Username:    username,
+		Path:        path,
+		AuthMethods: authMethods,
+	}
+	return newDirWatcher(&sftpLister{parameters: parameters, conn: util.NewSftpConnection(parameters)})
+}
+
+// SetSftpOptions sets how the connection to the sftp server is verified and
+// kept alive. It only applies to DirWatchers created with NewSftpDirWatcher.
+func (w *DirWatcher) SetSftpOptions(options util.SftpOptions) {
+	if l, ok := w.lister.(*sftpLister); ok && !l.byClient {
+		l.parameters.SftpOptions = options
+	}
+}
+
+// NewSftpDirWatcherByClient returns a new DirWatcher that polls the given directory
+// using an existing connection to the remote server. The connection will *not* be closed
+// in the Finish() func.
+func NewSftpDirWatcherByClient(client *sftp.Client, path string) *DirWatcher {
+	return newDirWatcher(&sftpLister{parameters: &util.SftpParameters{Path: path}, conn: util.NewSftpConnectionByClient(client), byClient: true})
+}
+
+// NewS3DirWatcher returns a new DirWatcher that polls all objects in the given S3
+// bucket that match a prefix. S3 Delimiter will be "/".
+func NewS3DirWatcher(awsID, awsSecret, awsRegion, bucket, prefix string) *DirWatcher {
+	creds := credentials.NewStaticCredentials(awsID, awsSecret, "")
+	conf := aws.NewConfig().WithRegion(awsRegion).WithDisableSSL(true).WithCredentials(creds)
+	return newDirWatcher(&s3Lister{client: s3.New(session.New(conf)), bucket: bucket, prefix: prefix})
+}
+
+func newDirWatcher(lister dirLister) *DirWatcher {
+	w := DirWatcher{
+		lister:     lister,
+		Interval:   30 * time.Second,
+		SettleTime: 5 * time.Second,
+		Ledger:     util.NewMemoryLedger(),
+		sent:       make(map[string]bool),
+	}
+	w.IoReader.LineByLine = true
+	w.IoReader.BufferSize = 1024
+	return &w
+}
+
+// ProcessData watches the directory, see Start.
+func (w *DirWatcher) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	w.Start(outputChan, killChan, ctx)
+}
+
+// Start watches the directory, sending each new file on to outputChan,
+// until ctx is done.
+func (w *DirWatcher) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	changed, err := w.lister.watch(ctx)
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
+	for {
+		wait := w.scan(outputChan, killChan, ctx)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Finish closes any connection opened by the DirWatcher.
+func (w *DirWatcher) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	w.lister.close()
+}
+
+// Commit records the files sent so far in the Ledger, deleting them if
+// DeleteObjects is set. It's called by the Pipeline once it has finished
+// with them (see ratchet.Committer). A file that can't be deleted is still
+// recorded, so it isn't sent again.
+func (w *DirWatcher) Commit() error {
+	w.Lock()
+	defer w.Unlock()
+	defer w.lister.close()
+	for len(w.pending) > 0 {
+		f := w.pending[0]
+		if err := w.Ledger.MarkProcessed(f.key); err != nil {
+			return err
+		}
+		w.pending = w.pending[1:]
+		delete(w.sent, f.key)
+		if w.DeleteObjects {
+			if err := w.lister.remove(f.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *DirWatcher) String() string {
+	return "DirWatcher"
+}
+
+// scan sends every settled file that hasn't been sent and isn't in the Ledger,
+// and returns how long to wait before the next scan.
+func (w *DirWatcher) scan(outputChan chan data.JSON, killChan chan error, ctx context.Context) time.Duration {
+	wait := w.Interval
+	files, err := w.lister.list()
+	if err != nil {
+		// Listing errors are usually transient (e.g. a dropped connection),
+		// so log and try again on the next scan rather than halting.
+		logger.Error("DirWatcher: error listing files -", err.Error())
+		return wait
+	}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return wait
+		}
+		key := fmt.Sprintf("%v@%d", f.Path, f.ModTime.UnixNano())
+		if w.wasSent(key) {
+			continue
+		}
+		processed, err := w.Ledger.Processed(key)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return wait
+		}
+		if processed {
+			continue
+		}
+		if age := time.Since(f.ModTime); age < w.SettleTime {
+			if w.SettleTime-age < wait {
+				wait = w.SettleTime - age
+			}
+			continue
+		}
+		logger.Debug("DirWatcher: sending", f.Path)
+		if err := w.send(f, outputChan, ctx); err != nil {
+			// A file cut short isn't marked as sent, so it's sent again
+			// in full on the next scan (or after a restart).
+			if ctx.Err() != nil {
+				return wait
+			}
+			logger.Error("DirWatcher: error sending", f.Path, "-", err.Error())
+			continue
+		}
+		w.Lock()
+		w.pending = append(w.pending, pendingFile{key: key, path: f.Path})
+		w.sent[key] = true
+		w.Unlock()
+	}
+	return wait
+}
+
+// wasSent reports whether the file with the given Ledger key has been sent,
+// but not yet committed.
+func (w *DirWatcher) wasSent(key string) bool {
+	w.Lock()
+	defer w.Unlock()
+	return w.sent[key]
+}
+
+func (w *DirWatcher) send(f WatchedFile, outputChan chan data.JSON, ctx context.Context) error {
+	if w.FileNamesOnly {
+		d, err := data.NewJSON(f)
+		if err != nil {
+			return err
+		}
+		select {
+		case outputChan <- d:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	file, err := w.lister.open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Use IoReader for actual data handling, catching any read error
+	// so the file isn't marked as processed.
+	readKillChan := make(chan error, 1)
+	r := w.IoReader
+	r.Reader = file
+	r.ProcessData(nil, outputChan, readKillChan, ctx)
+	select {
+	case err = <-readKillChan:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+type localLister struct {
+	dir string
+}
+
+func (l *localLister) list() ([]WatchedFile, error) {
+	infos, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	return watchedFiles(l.dir, infos, filepath.Join), nil
+}
+
+func (l *localLister) open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+func (l *localLister) remove(path string) error {
+	return os.Remove(path)
+}
+
+func (l *localLister) watch(ctx context.Context) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(l.dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) == 0 {
+					continue
+				}
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error("DirWatcher: fsnotify error -", err.Error())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changed, nil
+}
+
+func (l *localLister) close() {}
+
+type sftpLister struct {
+	parameters *util.SftpParameters
+	conn       *util.SftpConnection
+	byClient   bool
+}
+
+// retry calls f with the client (see SftpConnection.Retry). If f still fails,
+// the connection is dropped so it's re-established on the next poll.
+func (l *sftpLister) retry(f func(client *sftp.Client) error) error {
+	err := l.conn.Retry(f)
+	if err != nil && !l.byClient {
+		l.conn.Close()
+	}
+	return err
+}
+
+func (l *sftpLister) list() ([]WatchedFile, error) {
+	var infos []os.FileInfo
+	err := l.retry(func(client *sftp.Client) (err error) {
+		infos, err = client.ReadDir(l.parameters.Path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return watchedFiles(l.parameters.Path, infos, path.Join), nil
+}
+
+func (l *sftpLister) open(path string) (io.ReadCloser, error) {
+	var file *sftp.File
+	err := l.retry(func(client *sftp.Client) (err error) {
+		file, err = client.Open(path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (l *sftpLister) remove(path string) error {
+	return l.retry(func(client *sftp.Client) error {
+		return client.Remove(path)
+	})
+}
+
+func (l *sftpLister) watch(ctx context.Context) (<-chan struct{}, error) {
+	return nil, nil
+}
+
+func (l *sftpLister) close() {
+	if !l.byClient {
+		l.conn.Close()
+	}
+}
+
+type s3Lister struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func (l *s3Lister) list() ([]WatchedFile, error) {
+	objects, err := util.ListS3ObjectDetails(l.client, l.bucket, l.prefix)
+	if err != nil {
+		return nil, err
+	}
+	files := []WatchedFile{}
+	for _, o := range objects {
+		key := aws.StringValue(o.Key)
+		if strings.HasSuffix(key, "/") || strings.HasPrefix(path.Base(key), ".") {
+			continue
+		}
+		files = append(files, WatchedFile{Path: key, Size: aws.Int64Value(o.Size), ModTime: aws.TimeValue(o.LastModified)})
+	}
+	sortByModTime(files)
+	return files, nil
+}
+
+func (l *s3Lister) open(path string) (io.ReadCloser, error) {
+	obj, err := util.GetS3Object(l.client, l.bucket, path)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Body, nil
+}
+
+func (l *s3Lister) remove(path string) error {
+	_, err := util.DeleteS3Objects(l.client, l.bucket, []string{path})
+	return err
+}
+
+func (l *s3Lister) watch(ctx context.Context) (<-chan struct{}, error) {
+	return nil, nil
+}
+
+func (l *s3Lister) close() {}
+
+// watchedFiles converts a directory listing into WatchedFiles sorted by
+// modification time, skipping subdirectories and hidden files.
+func watchedFiles(dir string, infos []os.FileInfo, join func(...string) string) []WatchedFile {
+	files := []WatchedFile{}
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		files = append(files, WatchedFile{Path: join(dir, info.Name()), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sortByModTime(files)
+	return files
+}
+
+// sortByModTime sorts files oldest first, so they're sent in the order they
+// arrived. Files with the same modification time are sorted by path.
+func sortByModTime(files []WatchedFile) {
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].ModTime.Equal(files[j].ModTime) {
+			return files[i].Path < files[j].Path
+		}
+		return files[i].ModTime.Before(files[j].ModTime)
+	})
+}
@@ -0,0 +1,252 @@
+package processors_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// writeFile writes a file to dir, modified age ago.
+func writeFile(t *testing.T, dir, name, contents string, age time.Duration) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-age)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// watch runs w until it has sent n payloads, then for another wait
+// to check nothing else is sent, and returns what it sent.
+func watch(t *testing.T, w *processors.DirWatcher, n int, wait time.Duration) []data.JSON {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	outputChan := make(chan data.JSON)
+	killChan := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Start(outputChan, killChan, ctx)
+	}()
+
+	sent := []data.JSON{}
+	timeout := time.After(5 * time.Second)
+	for len(sent) < n {
+		select {
+		case d := <-outputChan:
+			sent = append(sent, d)
+		case err := <-killChan:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("DirWatcher sent %d payloads, want %d", len(sent), n)
+		}
+	}
+	select {
+	case d := <-outputChan:
+		sent = append(sent, d)
+	case <-time.After(wait):
+	}
+	cancel()
+	<-done
+	if len(sent) != n {
+		t.Fatalf("DirWatcher sent %d payloads, want %d", len(sent), n)
+	}
+	return sent
+}
+
+func TestDirWatcherOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dir_watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "a.txt", "a", 2*time.Hour)
+	writeFile(t, dir, "b.txt", "b", time.Hour)
+	writeFile(t, dir, "c.txt", "c", 3*time.Hour)
+	writeFile(t, dir, ".hidden", "hidden", 3*time.Hour)
+	writeFile(t, dir, "unsettled.txt", "unsettled", 0)
+
+	w := processors.NewDirWatcher(dir)
+	w.Interval = 10 * time.Millisecond
+	w.SettleTime = time.Minute
+	w.FileNamesOnly = true
+	sent := watch(t, w, 3, 100*time.Millisecond)
+
+	for i, want := range []string{"c.txt", "a.txt", "b.txt"} {
+		var f processors.WatchedFile
+		if err := json.Unmarshal(sent[i], &f); err != nil {
+			t.Fatal(err)
+		}
+		if f.Path != filepath.Join(dir, want) {
+			t.Errorf("file %d is %v, want %v", i, f.Path, want)
+		}
+	}
+}
+
+func TestDirWatcherSettleTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dir_watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "new.txt", "new", 0)
+
+	w := processors.NewDirWatcher(dir)
+	w.Interval = time.Hour
+	w.SettleTime = 200 * time.Millisecond
+	start := time.Now()
+	sent := watch(t, w, 1, 0)
+	if string(sent[0]) != "new" {
+		t.Errorf("sent %s, want new", sent[0])
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("file sent after %v, before the settle time", elapsed)
+	}
+}
+
+func TestDirWatcherLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dir_watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "lines.txt", "one\ntwo\nthree", time.Hour)
+	ledger := util.NewMemoryLedger()
+
+	// A watcher shut down before the file was fully sent
+	// mustn't mark it as processed.
+	w := processors.NewDirWatcher(dir)
+	w.Ledger = ledger
+	w.Interval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	outputChan := make(chan data.JSON)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Start(outputChan, make(chan error, 1), ctx)
+	}()
+	<-outputChan
+	cancel()
+	<-done
+
+	w = processors.NewDirWatcher(dir)
+	w.Ledger = ledger
+	w.Interval = 10 * time.Millisecond
+	sent := watch(t, w, 3, 0)
+	if string(sent[0]) != "one" || string(sent[2]) != "three" {
+		t.Errorf("sent %s", sent)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once sent in full and committed, the file isn't sent again.
+	w = processors.NewDirWatcher(dir)
+	w.Ledger = ledger
+	w.Interval = 10 * time.Millisecond
+	watch(t, w, 0, 100*time.Millisecond)
+}
+
+func TestDirWatcherCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dir_watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "a.txt", "a", time.Hour)
+	ledger := util.NewMemoryLedger()
+
+	w := processors.NewDirWatcher(dir)
+	w.Ledger = ledger
+	w.Interval = 10 * time.Millisecond
+	w.DeleteObjects = true
+	// The file is only sent once, though it's only committed afterwards.
+	watch(t, w, 1, 100*time.Millisecond)
+
+	key := func() string {
+		info, err := os.Stat(filepath.Join(dir, "a.txt"))
+		if err != nil {
+			t.Fatalf("file deleted before it was committed: %v", err)
+		}
+		return fmt.Sprintf("%v@%d", filepath.Join(dir, "a.txt"), info.ModTime().UnixNano())
+	}()
+	if processed, _ := ledger.Processed(key); processed {
+		t.Error("file recorded in the Ledger before it was committed")
+	}
+
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if processed, _ := ledger.Processed(key); !processed {
+		t.Error("committed file wasn't recorded in the Ledger")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("committed file wasn't deleted: %v", err)
+	}
+}
+
+// TestDirWatcherRetries checks a file that can't be opened doesn't halt the
+// DirWatcher, and is sent once it can be.
+func TestDirWatcherRetries(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	dir, err := ioutil.TempDir("", "dir_watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, ".target")
+	if err := os.Symlink(target, filepath.Join(dir, "link.txt")); err != nil {
+		t.Skip("can't create a symlink:", err)
+	}
+	writeFile(t, dir, "ok.txt", "ok", time.Hour)
+
+	w := processors.NewDirWatcher(dir)
+	w.Interval = 10 * time.Millisecond
+	w.SettleTime = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	outputChan := make(chan data.JSON)
+	killChan := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Start(outputChan, killChan, ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	receive := func(want string) {
+		t.Helper()
+		select {
+		case d := <-outputChan:
+			if string(d) != want {
+				t.Errorf("sent %s, want %s", d, want)
+			}
+		case err := <-killChan:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s wasn't sent", want)
+		}
+	}
+	receive("ok")
+	// The broken link is tried on every scan, until its target exists.
+	time.Sleep(50 * time.Millisecond)
+	if err := ioutil.WriteFile(target, []byte("fixed"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	receive("fixed")
+}
@@ -0,0 +1,241 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// LookupBackend looks up the fields to add to a payload by key, see Enricher.
+// Lookup returns nil (and no error) if there's nothing found for key.
+// Lookups may be made concurrently.
+type LookupBackend interface {
+	Lookup(key string, ctx context.Context) (map[string]interface{}, error)
+}
+
+// MissPolicy controls what an Enricher does with an object
+// when nothing is found for its key.
+type MissPolicy int
+
+const (
+	// MissPassThrough sends the object on without enrichment. This is the default.
+	MissPassThrough MissPolicy = iota
+	// MissDrop drops the object.
+	MissDrop
+	// MissError sends an error to the killChan, dropping the payload.
+	MissError
+)
+
+func (p MissPolicy) String() string {
+	switch p {
+	case MissPassThrough:
+		return "pass"
+	case MissDrop:
+		return "drop"
+	case MissError:
+		return "error"
+	}
+	return fmt.Sprintf("MissPolicy(%d)", int(p))
+}
+
+// MarshalJSON encodes the policy as "pass", "drop", or "error".
+func (p MissPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON decodes a policy from "pass", "drop", or "error".
+func (p *MissPolicy) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	switch s {
+	case "pass", "":
+		*p = MissPassThrough
+	case "drop":
+		*p = MissDrop
+	case "error":
+		*p = MissError
+	default:
+		return fmt.Errorf("unknown miss policy %q, must be pass, drop, or error", s)
+	}
+	return nil
+}
+
+// Enricher adds fields to each object it receives, looked up from a
+// LookupBackend by the value of the object's KeyField. This is typically
+// used to join against a dimension table, e.g. adding a customer's details
+// to orders by customer_id. See SQLLookup, RedisLookup, HTTPLookup, and
+// MemoryLookup for the built-in backends.
+//
+// Received data must be a JSON object or a slice of objects. The looked up
+// fields (or just Fields, if set) are added to the object, replacing any
+// existing fields of the same name, or nested under the Into field if set.
+// Objects without a KeyField, or with nothing found for their key, are
+// handled according to the MissPolicy. A payload is dropped on its first
+// error, so only that error is sent, even for a slice of objects.
+//
+// Lookups (including misses) are cached in an LRU cache of CacheSize entries,
+// which expire after TTL (if set). Set CacheSize to -1 to disable caching,
+// e.g. for a MemoryLookup.
+type Enricher struct {
+	Backend          LookupBackend
+	KeyField         string
+	Fields           []string
+	Into             string
+	Miss             MissPolicy
+	CacheSize        int           // Default is 10000.
+	TTL              time.Duration // Default is to never expire.
+	ConcurrencyLevel int           // See ConcurrentDataProcessor
+	cache            *util.LRUCache
+	cacheOnce        sync.Once
+}
+
+// NewEnricher returns a new Enricher looking up KeyField in backend.
+func NewEnricher(backend LookupBackend, keyField string) *Enricher {
+	return &Enricher{Backend: backend, KeyField: keyField, CacheSize: 10000}
+}
+
+// ProcessData enriches each object in d, sending the result on.
+func (e *Enricher) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		return
+	}
+
+	var out interface{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		ok, err := e.enrich(v, ctx)
+		util.KillPipelineIfErr(err, killChan, ctx)
+		if !ok || err != nil {
+			return
+		}
+		out = v
+	case []interface{}:
+		objects := []interface{}{}
+		for _, o := range v {
+			object, isObject := o.(map[string]interface{})
+			if !isObject {
+				util.KillPipelineIfErr(fmt.Errorf("Enricher: expected a JSON object, got %v", o), killChan, ctx)
+				return
+			}
+			ok, err := e.enrich(object, ctx)
+			if err != nil {
+				util.KillPipelineIfErr(err, killChan, ctx)
+				return
+			}
+			if ok {
+				objects = append(objects, object)
+			}
+		}
+		if len(objects) == 0 {
+			return
+		}
+		out = objects
+	default:
+		util.KillPipelineIfErr(fmt.Errorf("Enricher: expected a JSON object or slice of objects, got %v", v), killChan, ctx)
+		return
+	}
+
+	d, err = data.NewJSON(out)
+	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		return
+	}
+	select {
+	case outputChan <- d:
+	case <-ctx.Done():
+	}
+}
+
+// Finish - see interface for documentation.
+func (e *Enricher) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (e *Enricher) String() string {
+	return "Enricher"
+}
+
+// Concurrency defers to ConcurrentDataProcessor
+func (e *Enricher) Concurrency() int {
+	return e.ConcurrencyLevel
+}
+
+// enrich adds the fields looked up for object, returning false
+// if the object should be dropped according to the MissPolicy.
+func (e *Enricher) enrich(object map[string]interface{}, ctx context.Context) (bool, error) {
+	key, ok := object[e.KeyField]
+	if !ok || key == nil {
+		return e.miss(fmt.Sprintf("no %v field", e.KeyField))
+	}
+	fields, err := e.lookup(fmt.Sprint(key), ctx)
+	if err != nil {
+		return false, err
+	}
+	if fields == nil {
+		return e.miss(fmt.Sprintf("nothing found for %v %v", e.KeyField, key))
+	}
+
+	if len(e.Fields) > 0 {
+		selected := make(map[string]interface{}, len(e.Fields))
+		for _, f := range e.Fields {
+			if v, ok := fields[f]; ok {
+				selected[f] = v
+			}
+		}
+		fields = selected
+	}
+	if e.Into != "" {
+		object[e.Into] = fields
+		return true, nil
+	}
+	for f, v := range fields {
+		object[f] = v
+	}
+	return true, nil
+}
+
+func (e *Enricher) miss(reason string) (bool, error) {
+	switch e.Miss {
+	case MissDrop:
+		logger.Debug("Enricher: dropping object,", reason)
+		return false, nil
+	case MissError:
+		return false, fmt.Errorf("Enricher: %v", reason)
+	}
+	return true, nil
+}
+
+func (e *Enricher) lookup(key string, ctx context.Context) (map[string]interface{}, error) {
+	if e.CacheSize < 0 {
+		return e.Backend.Lookup(key, ctx)
+	}
+	e.cacheOnce.Do(func() {
+		size := e.CacheSize
+		if size == 0 {
+			size = 10000
+		}
+		e.cache = util.NewLRUCache(size, e.TTL)
+	})
+	if fields, ok := e.cache.Get(key); ok {
+		return fields.(map[string]interface{}), nil
+	}
+	fields, err := e.Backend.Lookup(key, ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.cache.Add(key, fields)
+	return fields, nil
+}
@@ -0,0 +1,174 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// MemoryLookup is a LookupBackend holding every record in memory, loaded
+// on the first lookup (so when the first payload is received, not when the
+// Pipeline starts). If loading fails, the error is returned and loading is
+// tried again on the next lookup. It suits small dimension tables, which can
+// then be joined against without a query per key.
+type MemoryLookup struct {
+	load    func(ctx context.Context) (map[string]map[string]interface{}, error)
+	records map[string]map[string]interface{}
+	loaded  bool
+	mu      sync.Mutex
+}
+
+// NewMemoryLookup returns a MemoryLookup of the given records, by key.
+func NewMemoryLookup(records map[string]map[string]interface{}) *MemoryLookup {
+	return &MemoryLookup{load: func(ctx context.Context) (map[string]map[string]interface{}, error) {
+		return records, nil
+	}}
+}
+
+// NewSQLMemoryLookup returns a MemoryLookup loading the rows returned
+// by query, keyed by the value of their keyColumn.
+func NewSQLMemoryLookup(db *sql.DB, query, keyColumn string) *MemoryLookup {
+	return &MemoryLookup{load: func(ctx context.Context) (map[string]map[string]interface{}, error) {
+		objects, err := util.SQLQueryObjects(db, query, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return recordsByKey(objects, keyColumn)
+	}}
+}
+
+// NewJSONMemoryLookup returns a MemoryLookup loading the slice of
+// objects in d, keyed by the value of their keyField.
+func NewJSONMemoryLookup(d data.JSON, keyField string) *MemoryLookup {
+	return &MemoryLookup{load: func(ctx context.Context) (map[string]map[string]interface{}, error) {
+		objects, err := decodeObjects(d)
+		if err != nil {
+			return nil, err
+		}
+		return recordsByKey(objects, keyField)
+	}}
+}
+
+// Lookup returns the record for key.
+func (l *MemoryLookup) Lookup(key string, ctx context.Context) (map[string]interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.loaded {
+		records, err := l.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		l.records, l.loaded = records, true
+	}
+	return l.records[key], nil
+}
+
+func recordsByKey(objects []map[string]interface{}, keyField string) (map[string]map[string]interface{}, error) {
+	records := make(map[string]map[string]interface{}, len(objects))
+	for _, o := range objects {
+		key, ok := o[keyField]
+		if !ok || key == nil {
+			return nil, fmt.Errorf("lookup record has no %v: %v", keyField, o)
+		}
+		records[fmt.Sprint(key)] = o
+	}
+	return records, nil
+}
+
+// SQLLookup is a LookupBackend running a query for each key, which is passed
+// as the query's only argument, e.g. "SELECT * FROM customers WHERE id = ?"
+// (using the placeholder syntax of the database driver). The first row
+// returned is the lookup's result.
+type SQLLookup struct {
+	db    *sql.DB
+	query string
+}
+
+// NewSQLLookup returns a new SQLLookup.
+func NewSQLLookup(db *sql.DB, query string) *SQLLookup {
+	return &SQLLookup{db: db, query: query}
+}
+
+// Lookup runs the query for key.
+func (l *SQLLookup) Lookup(key string, ctx context.Context) (map[string]interface{}, error) {
+	objects, err := util.SQLQueryObjects(l.db, l.query, ctx, key)
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	return objects[0], nil
+}
+
+// HTTPLookup is a LookupBackend requesting a URL for each key, where "{key}"
+// in the URL is replaced by the (escaped) key, e.g.
+// "https://api.example.com/customers/{key}". A 404 Not Found response means
+// there's nothing found for the key, otherwise the response must be a JSON object.
+type HTTPLookup struct {
+	URL    string
+	Header http.Header
+	Client *http.Client
+}
+
+// NewHTTPLookup returns a new HTTPLookup.
+func NewHTTPLookup(url string) *HTTPLookup {
+	return &HTTPLookup{URL: url, Header: http.Header{}, Client: &http.Client{}}
+}
+
+// Lookup requests the URL for key.
+func (l *HTTPLookup) Lookup(key string, ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", strings.Replace(l.URL, "{key}", url.PathEscape(key), -1), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for name, values := range l.Header {
+		req.Header[name] = values
+	}
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("HTTPLookup: %v returned %v", req.URL, resp.Status)
+	}
+	return decodeObject(body)
+}
+
+// decodeObject decodes a JSON object, keeping numbers in their original text form.
+func decodeObject(d []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	var object map[string]interface{}
+	if err := dec.Decode(&object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// decodeObjects decodes a slice of JSON objects, keeping numbers in their original text form.
+func decodeObjects(d []byte) ([]map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	var objects []map[string]interface{}
+	if err := dec.Decode(&objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
@@ -0,0 +1,157 @@
+package processors_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+// countingLookup counts the lookups made of a MemoryLookup, failing for "bad".
+type countingLookup struct {
+	*processors.MemoryLookup
+	lookups int
+}
+
+func (l *countingLookup) Lookup(key string, ctx context.Context) (map[string]interface{}, error) {
+	l.lookups++
+	if key == "bad" {
+		return nil, errors.New("lookup failed")
+	}
+	return l.MemoryLookup.Lookup(key, ctx)
+}
+
+func newCustomers() *countingLookup {
+	return &countingLookup{MemoryLookup: processors.NewMemoryLookup(map[string]map[string]interface{}{
+		"1": {"name": "Ann", "tier": "gold"},
+	})}
+}
+
+func TestEnricherMissPolicies(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	input := data.JSON(`[{"id":1},{"id":2},{"other":3}]`)
+	tests := []struct {
+		miss    processors.MissPolicy
+		outputs []string
+		err     string
+	}{
+		{miss: processors.MissPassThrough, outputs: []string{`[{"id":1,"name":"Ann","tier":"gold"},{"id":2},{"other":3}]`}},
+		{miss: processors.MissDrop, outputs: []string{`[{"id":1,"name":"Ann","tier":"gold"}]`}},
+		{miss: processors.MissError, err: "nothing found for id 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.miss.String(), func(t *testing.T) {
+			e := processors.NewEnricher(newCustomers(), "id")
+			e.Miss = tt.miss
+			r := ratchettest.Process(t, e, input)
+			r.AssertOutputs(t, tt.outputs...)
+			if tt.err == "" {
+				r.AssertNoErrors(t)
+				return
+			}
+			r.AssertError(t, tt.err)
+			if len(r.Errors) != 1 {
+				t.Errorf("got errors %v, want only the first", r.Errors)
+			}
+		})
+	}
+}
+
+func TestEnricherDropsMissedObject(t *testing.T) {
+	e := processors.NewEnricher(newCustomers(), "id")
+	e.Miss = processors.MissDrop
+	r := ratchettest.Process(t, e, data.JSON(`{"id":2}`), data.JSON(`[{"id":3}]`))
+	r.AssertOutputs(t)
+	r.AssertNoErrors(t)
+}
+
+func TestEnricherFieldsAndInto(t *testing.T) {
+	e := processors.NewEnricher(newCustomers(), "id")
+	e.Fields = []string{"tier"}
+	e.Into = "customer"
+	r := ratchettest.Process(t, e, data.JSON(`{"id":1,"tier":"none"}`))
+	r.AssertOutputs(t, `{"id":1,"tier":"none","customer":{"tier":"gold"}}`)
+	r.AssertNoErrors(t)
+}
+
+func TestEnricherLookupErrors(t *testing.T) {
+	backend := newCustomers()
+	e := processors.NewEnricher(backend, "id")
+	r := ratchettest.Process(t, e, data.JSON(`{"id":"bad"}`), data.JSON(`{"id":"bad"}`))
+	r.AssertOutputs(t)
+	r.AssertError(t, "lookup failed")
+	if len(r.Errors) != 2 || backend.lookups != 2 {
+		t.Errorf("got %d errors from %d lookups, want failed lookups not to be cached", len(r.Errors), backend.lookups)
+	}
+}
+
+func TestEnricherCache(t *testing.T) {
+	inputs := []data.JSON{data.JSON(`{"id":1}`), data.JSON(`{"id":2}`), data.JSON(`{"id":1}`), data.JSON(`{"id":2}`)}
+
+	backend := newCustomers()
+	r := ratchettest.Process(t, processors.NewEnricher(backend, "id"), inputs...)
+	r.AssertNoErrors(t)
+	if backend.lookups != 2 {
+		t.Errorf("made %d lookups, want hits and misses cached", backend.lookups)
+	}
+
+	backend = newCustomers()
+	e := processors.NewEnricher(backend, "id")
+	e.CacheSize = -1
+	ratchettest.Process(t, e, inputs...)
+	if backend.lookups != 4 {
+		t.Errorf("made %d lookups with caching disabled, want 4", backend.lookups)
+	}
+}
+
+func TestEnricherCancelled(t *testing.T) {
+	e := processors.NewEnricher(newCustomers(), "id")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		// Nothing receives from the outputChan, so this returns only if
+		// ProcessData gives up sending when ctx is done.
+		e.ProcessData(data.JSON(`{"id":1}`), make(chan data.JSON), make(chan error, 1), ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ratchettest.Timeout):
+		t.Fatal("ProcessData didn't return after ctx was done")
+	}
+}
+
+func TestMemoryLookupRetriesLoad(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT").WillReturnError(errors.New("db down"))
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Ann"))
+
+	l := processors.NewSQLMemoryLookup(db, "SELECT id, name FROM customers", "id")
+	if _, err := l.Lookup("1", context.Background()); err == nil || err.Error() != "db down" {
+		t.Fatalf("got error %v, want db down", err)
+	}
+	// The failed load is tried again, and then the records are kept.
+	for i := 0; i < 2; i++ {
+		record, err := l.Lookup("1", context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record["name"] != "Ann" {
+			t.Errorf("got record %v, want Ann's", record)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -9,6 +9,9 @@ import (
 )
 
 // FileReader opens and reads the contents of the given filename.
+//
+// FileReader is a ratchet.Source, so it's typically used in the first stage
+// of a Pipeline. In later stages, it reads the file again for every payload received.
 type FileReader struct {
 	filename string
 }
@@ -20,11 +23,22 @@ func NewFileReader(filename string) *FileReader {
 	return &FileReader{filename: filename}
 }
 
-// ProcessData reads a file and sends its contents to outputChan
+// ProcessData calls Start, ignoring the data received.
 func (r *FileReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.Start(outputChan, killChan, ctx)
+}
+
+// Start reads a file and sends its contents to outputChan
+func (r *FileReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	d, err := ioutil.ReadFile(r.filename)
-	util.KillPipelineIfErr(err, killChan, ctx)
-	outputChan <- d
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
+	select {
+	case outputChan <- d:
+	case <-ctx.Done():
+	}
 }
 
 // Finish - see interface for documentation.
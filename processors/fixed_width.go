@@ -0,0 +1,135 @@
+package processors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// FixedWidthReader parses fixed-width (e.g. mainframe-style) records into
+// data.JSON objects, using the column offsets defined by Layout. Each line
+// is one record. Values are trimmed of surrounding spaces and, if NullValue
+// is set, values equal to it are sent as null.
+//
+// A FixedWidthReader created with NewFixedWidthReader reads a file and sends
+// all of its records as a single slice of objects, like CSVReader. One created
+// with NewFixedWidthParser instead parses the data it receives, e.g. from an
+// SftpReader or IoReader, sending a slice of objects for each payload.
+//
+// FixedWidthReader is a ratchet.Source, so a reader is typically used in the
+// first stage of a Pipeline (where a parser has nothing to parse).
+type FixedWidthReader struct {
+	filename  string
+	Layout    util.FixedWidthLayout
+	NullValue string
+	// SkipLines is the number of lines (e.g. a header) to skip at
+	// the start of the file. It's ignored by parsers.
+	SkipLines int
+}
+
+// NewFixedWidthReader creates a FixedWidthReader that will read the file
+// using the given layout, or returns an error if the layout isn't valid.
+func NewFixedWidthReader(filename string, layout util.FixedWidthLayout) (*FixedWidthReader, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return &FixedWidthReader{filename: filename, Layout: layout}, nil
+}
+
+// NewFixedWidthParser creates a FixedWidthReader that will parse the data it
+// receives using the given layout, or returns an error if the layout isn't valid.
+func NewFixedWidthParser(layout util.FixedWidthLayout) (*FixedWidthReader, error) {
+	return NewFixedWidthReader("", layout)
+}
+
+// Start reads the file, as ProcessData does for a reader.
+func (r *FixedWidthReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.ProcessData(nil, outputChan, killChan, ctx)
+}
+
+func (r *FixedWidthReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	var in io.Reader = bytes.NewReader(d)
+	skip := 0
+	if r.filename != "" {
+		f, err := os.Open(r.filename)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
+		defer f.Close()
+		in = f
+		skip = r.SkipLines
+	}
+
+	res := []map[string]interface{}{}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if skip > 0 {
+			skip--
+			continue
+		}
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		res = append(res, r.Layout.Parse(line, r.NullValue))
+	}
+	if err := scanner.Err(); err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
+	if len(res) == 0 {
+		return
+	}
+
+	jd, err := data.NewJSON(res)
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
+	select {
+	case outputChan <- jd:
+	case <-ctx.Done():
+	}
+}
+
+// Finish - see interface for documentation.
+func (r *FixedWidthReader) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (r *FixedWidthReader) String() string {
+	return "FixedWidthReader"
+}
+
+// NewFixedWidthWriter returns a CSVWriter that writes data.JSON objects to
+// the given io.Writer as fixed-width records using the given layout, or an
+// error if the layout isn't valid. Values are padded or truncated to fit
+// their columns, and no header is written.
+func NewFixedWidthWriter(w io.Writer, layout util.FixedWidthLayout) (*CSVWriter, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	writer := NewCSVWriter(w)
+	writer.Parameters.Writer.FixedWidth = layout
+	writer.Parameters.WriteHeader = false
+	return writer, nil
+}
+
+// NewFixedWidthTransformer returns a CSVTransformer that converts data.JSON
+// objects into fixed-width records using the given layout, sending them on
+// to the next stage. It returns an error if the layout isn't valid.
+func NewFixedWidthTransformer(layout util.FixedWidthLayout) (*CSVTransformer, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	t := NewCSVTransformer()
+	t.Parameters.Writer.FixedWidth = layout
+	t.Parameters.WriteHeader = false
+	return t, nil
+}
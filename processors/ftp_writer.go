@@ -28,15 +28,15 @@ func NewFtpWriter(host, username, password, path string) *FtpWriter {
 }
 
 // connect - opens a connection to the provided ftp host and then authenticates with the host with the username, password attributes
-func (f *FtpWriter) connect(killChan chan error, ctx context.Context) {
+func (f *FtpWriter) connect() error {
 	conn, err := ftp.Dial(f.host)
 	if err != nil {
-		util.KillPipelineIfErr(err, killChan, ctx)
+		return err
 	}
 
-	lerr := conn.Login(f.username, f.password)
-	if lerr != nil {
-		util.KillPipelineIfErr(lerr, killChan, ctx)
+	if err := conn.Login(f.username, f.password); err != nil {
+		conn.Quit()
+		return err
 	}
 
 	r, w := io.Pipe()
@@ -45,13 +45,17 @@ func (f *FtpWriter) connect(killChan chan error, ctx context.Context) {
 	go f.conn.Stor(f.path, r)
 	f.fileWriter = w
 	f.authenticated = true
+	return nil
 }
 
 // ProcessData writes data as is directly to the output file
 func (f *FtpWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	logger.Debug("FTPWriter Process data:", string(d))
 	if !f.authenticated {
-		f.connect(killChan, ctx)
+		if err := f.connect(); err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
 	}
 
 	_, e := f.fileWriter.Write([]byte(d))
@@ -61,7 +65,7 @@ func (f *FtpWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan
 }
 
 // Finish closes open references to the remote file and server
-func (f *FtpWriter) Finish(outputChan chan data.JSON, killChan chan error) {
+func (f *FtpWriter) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	if f.fileWriter != nil {
 		f.fileWriter.Close()
 	}
@@ -1,6 +1,10 @@
 package processors
 
-import "github.com/rhansen2/ratchet/data"
+import (
+	"context"
+
+	"github.com/rhansen2/ratchet/data"
+)
 
 // FuncTransformer executes the given function on each data
 // payload, sending the resuling data to the next stage.
@@ -19,12 +23,15 @@ func NewFuncTransformer(transform func(d data.JSON) data.JSON) *FuncTransformer
 }
 
 // ProcessData runs the supplied func and sends the returned value to outputChan
-func (t *FuncTransformer) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error) {
-	outputChan <- t.transform(d)
+func (t *FuncTransformer) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	select {
+	case outputChan <- t.transform(d):
+	case <-ctx.Done():
+	}
 }
 
 // Finish - see interface for documentation.
-func (t *FuncTransformer) Finish(outputChan chan data.JSON, killChan chan error) {
+func (t *FuncTransformer) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 }
 
 func (t *FuncTransformer) String() string {
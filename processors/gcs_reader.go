@@ -0,0 +1,192 @@
+package processors
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// GCSReader handles retrieving objects from Google Cloud Storage. Use
+// NewGCSObjectReader to read a single object, or NewGCSPrefixReader to read
+// all objects matching the same prefix in your bucket.
+// Like S3Reader, GCSReader embeds an IoReader, so it supports the same
+// configuration options as IoReader.
+//
+// For large objects, see ChunkedTransfer for chunked, resumable transfers
+// (using ranged reads) and checksum verification (md5 only, which isn't
+// published for composite objects).
+//
+// GCSReader is a ratchet.Source, so it's typically used in the first stage
+// of a Pipeline. In later stages, it reads the objects again for every payload received.
+type GCSReader struct {
+	IoReader         // embeds IoReader
+	ChunkedTransfer  // embeds ChunkedTransfer
+	bucket           string
+	object           string
+	prefix           string
+	DeleteObjects    bool
+	processedObjects []string
+	client           *storage.Client
+}
+
+// NewGCSObjectReader reads a single object from the given bucket, using the
+// given client (see storage.NewClient for configuring credentials).
+func NewGCSObjectReader(client *storage.Client, bucket, object string) *GCSReader {
+	r := GCSReader{bucket: bucket, object: object, client: client}
+	r.IoReader.LineByLine = true
+	return &r
+}
+
+// NewGCSPrefixReader reads all objects from the given bucket that match a
+// prefix. As with NewS3PrefixReader, the delimiter is "/".
+func NewGCSPrefixReader(client *storage.Client, bucket, prefix string) *GCSReader {
+	r := NewGCSObjectReader(client, bucket, "")
+	r.prefix = prefix
+	return r
+}
+
+// ProcessData calls Start, ignoring the data received.
+func (r *GCSReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.Start(outputChan, killChan, ctx)
+}
+
+// Start reads all objects matching the prefix if one is provided (sending each
+// object to outputChan), or just sends the single object to outputChan.
+//
+// It optionally deletes all processed objects once every object has been sent to outputChan,
+// so nothing is deleted if the pipeline is halted or shut down first.
+func (r *GCSReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	if r.prefix != "" {
+		logger.Debug("GCSReader: process data for prefix", r.prefix)
+		objects, err := util.ListGCSObjects(r.client, r.bucket, r.prefix, ctx)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
+		for _, o := range objects {
+			if err := r.sendObject(o, 0, outputChan, ctx); err != nil {
+				util.KillPipelineIfErr(err, killChan, ctx)
+				return
+			}
+			r.processedObjects = append(r.processedObjects, o)
+		}
+	} else {
+		logger.Debug("GCSReader: process data for object", r.object)
+		if err := r.sendObject(r.object, r.ResumeFrom, outputChan, ctx); err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
+		r.processedObjects = append(r.processedObjects, r.object)
+	}
+	if r.DeleteObjects {
+		err := util.DeleteGCSObjects(r.client, r.bucket, r.processedObjects, ctx)
+		util.KillPipelineIfErr(err, killChan, ctx)
+	}
+}
+
+// Finish - see interface for documentation.
+func (r *GCSReader) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+// sendObject sends the named object, returning ctx.Err()
+// if ctx is done before it has been sent in full.
+func (r *GCSReader) sendObject(name string, resumeFrom int64, outputChan chan data.JSON, ctx context.Context) error {
+	handle := r.client.Bucket(r.bucket).Object(name)
+	if r.chunked() {
+		attrs, err := handle.Attrs(ctx)
+		if err != nil {
+			return err
+		}
+		// Pin the generation read, so each chunk comes from the same
+		// version of the object even if it's overwritten mid-transfer.
+		handle = handle.Generation(attrs.Generation)
+		obj := &remoteObject{
+			path:           name,
+			size:           attrs.Size,
+			version:        strconv.FormatInt(attrs.Generation, 10),
+			resumeFrom:     resumeFrom,
+			reader:         &gcsRangeReader{handle: handle, ctx: ctx},
+			serverChecksum: gcsChecksum(name, attrs),
+		}
+		return r.sendChunks(obj, outputChan, ctx)
+	}
+
+	obj, err := handle.NewReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	reader, h, err := r.hashReader(obj)
+	if err != nil {
+		return err
+	}
+	// Use IoReader for actual data handling
+	errChan := make(chan error, 1)
+	r.IoReader.Reader = reader
+	r.IoReader.ProcessData(nil, outputChan, errChan, ctx)
+	select {
+	case err := <-errChan:
+		return err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ro := &remoteObject{
+		path: name,
+		size: obj.Attrs.Size,
+		serverChecksum: func(algorithm string) (string, error) {
+			attrs, err := handle.Generation(obj.Attrs.Generation).Attrs(ctx)
+			if err != nil {
+				return "", err
+			}
+			return gcsChecksum(name, attrs)(algorithm)
+		},
+	}
+	return r.complete(ro, h, 0, outputChan, ctx)
+}
+
+// gcsRangeReader implements io.ReaderAt using ranged reads, so that each
+// chunk of a ChunkedTransfer is its own request.
+type gcsRangeReader struct {
+	handle *storage.ObjectHandle
+	ctx    context.Context
+}
+
+func (g *gcsRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	obj, err := g.handle.NewRangeReader(g.ctx, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer obj.Close()
+	n, err := io.ReadFull(obj, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// gcsChecksum returns a func checking an object's md5, which
+// Google Cloud Storage doesn't publish for composite objects.
+func gcsChecksum(name string, attrs *storage.ObjectAttrs) func(string) (string, error) {
+	return func(algorithm string) (string, error) {
+		if algorithm != "md5" || len(attrs.MD5) == 0 {
+			return "", fmt.Errorf("GCSReader: no %v checksum available to verify %v", algorithm, name)
+		}
+		return hex.EncodeToString(attrs.MD5), nil
+	}
+}
+
+func (r *GCSReader) String() string {
+	return "GCSReader"
+}
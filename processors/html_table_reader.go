@@ -0,0 +1,139 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// HTMLTableReader extracts rows from HTML tables. It parses the HTML it
+// receives (e.g. the response body from an HTTPRequest) and, for each table
+// matching Selector, sends a slice of objects with one object per row.
+//
+// Column names are taken from the table's header cells (th), or from its first
+// row if it has none, unless Header is set. Cells spanning several columns
+// are repeated for each column. Unnamed columns are named column_N, and
+// repeated names (e.g. from a header cell spanning several columns) are
+// suffixed with _2, _3, and so on.
+type HTMLTableReader struct {
+	Selector string   // CSS selector for the table(s) to read, e.g. "table.prices".
+	Header   []string // Overrides the column names.
+}
+
+// NewHTMLTableReader returns a new HTMLTableReader reading the tables matching selector.
+func NewHTMLTableReader(selector string) *HTMLTableReader {
+	return &HTMLTableReader{Selector: selector}
+}
+
+// ProcessData sends the rows of each matching table as a slice of objects.
+func (r *HTMLTableReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(d))
+	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		return
+	}
+	doc.Find(r.Selector).EachWithBreak(func(i int, table *goquery.Selection) bool {
+		rows := r.readTable(table)
+		if len(rows) == 0 {
+			return true
+		}
+		jd, err := data.NewJSON(rows)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return false
+		}
+		select {
+		case outputChan <- jd:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// Finish - see interface for documentation.
+func (r *HTMLTableReader) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (r *HTMLTableReader) String() string {
+	return "HTMLTableReader"
+}
+
+func (r *HTMLTableReader) readTable(table *goquery.Selection) []map[string]interface{} {
+	// Only read rows belonging to this table, not to tables nested in it.
+	trs := table.Find("tr").FilterFunction(func(i int, tr *goquery.Selection) bool {
+		return tr.Closest("table").IsSelection(table)
+	})
+
+	header := r.Header
+	rows := []map[string]interface{}{}
+	trs.Each(func(i int, tr *goquery.Selection) {
+		cells := htmlRowCells(tr)
+		if header == nil {
+			header = htmlColumnNames(cells)
+			return
+		}
+		if tr.Children().Filter("td").Length() == 0 {
+			// Skip any further header rows.
+			return
+		}
+		row := make(map[string]interface{}, len(header))
+		for j, name := range header {
+			row[htmlColumnName(name, j+1)] = nil
+		}
+		for j, v := range cells {
+			name := ""
+			if j < len(header) {
+				name = header[j]
+			}
+			row[htmlColumnName(name, j+1)] = v
+		}
+		rows = append(rows, row)
+	})
+	return rows
+}
+
+// htmlRowCells returns the text of each cell in the row, repeating
+// cells that span several columns.
+func htmlRowCells(tr *goquery.Selection) []string {
+	cells := []string{}
+	tr.Children().Filter("th, td").Each(func(i int, cell *goquery.Selection) {
+		text := strings.Join(strings.Fields(cell.Text()), " ")
+		span, err := strconv.Atoi(cell.AttrOr("colspan", "1"))
+		if err != nil || span < 1 {
+			span = 1
+		}
+		for ; span > 0; span-- {
+			cells = append(cells, text)
+		}
+	})
+	return cells
+}
+
+// htmlColumnNames makes the column names read from a header row unique.
+func htmlColumnNames(cells []string) []string {
+	names := make([]string, len(cells))
+	seen := make(map[string]int)
+	for i, name := range cells {
+		name = htmlColumnName(name, i+1)
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%v_%d", name, seen[name])
+		}
+		names[i] = name
+	}
+	return names
+}
+
+func htmlColumnName(name string, n int) string {
+	if name == "" {
+		return fmt.Sprintf("column_%d", n)
+	}
+	return name
+}
@@ -0,0 +1,52 @@
+package processors_test
+
+import (
+	"testing"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+const pricesHTML = `<html><body>
+<table class="prices">
+  <thead><tr><th>Item</th><th colspan="2">Price</th></tr></thead>
+  <tbody>
+    <tr><td>Apple</td><td>1.50</td><td>USD</td></tr>
+    <tr><td colspan="2">Pear</td></tr>
+  </tbody>
+</table>
+<table class="prices" id="plain">
+  <tr><td>Name</td><td></td></tr>
+  <tr><td> Ada
+    Lovelace </td><td>1815</td><td>London</td></tr>
+</table>
+<table class="prices"><tr><th>Empty</th></tr></table>
+<table class="other"><tr><th>Ignored</th></tr><tr><td>x</td></tr></table>
+</body></html>`
+
+func TestHTMLTableReader(t *testing.T) {
+	r := processors.NewHTMLTableReader("table.prices")
+	result := ratchettest.Process(t, r, data.JSON(pricesHTML))
+	result.AssertNoErrors(t)
+	result.AssertOutputs(t,
+		// Cells spanning several columns are repeated, and
+		// repeated header names are made unique.
+		`[{"Item": "Apple", "Price": "1.50", "Price_2": "USD"},
+		  {"Item": "Pear", "Price": "Pear", "Price_2": null}]`,
+		// Without th cells, the first row names the columns.
+		`[{"Name": "Ada Lovelace", "column_2": "1815", "column_3": "London"}]`,
+	)
+}
+
+func TestHTMLTableReaderHeader(t *testing.T) {
+	r := processors.NewHTMLTableReader("#plain, table.other")
+	r.Header = []string{"name", "born"}
+	result := ratchettest.Process(t, r, data.JSON(pricesHTML))
+	result.AssertNoErrors(t)
+	result.AssertOutputs(t,
+		`[{"name": "Name", "born": ""},
+		  {"name": "Ada Lovelace", "born": "1815", "column_3": "London"}]`,
+		`[{"name": "x", "born": null}]`,
+	)
+}
@@ -28,11 +28,17 @@ func NewHTTPRequest(method, url string, body io.Reader) (*HTTPRequest, error) {
 // ProcessData sends data to outputChan if the response body is not null
 func (r *HTTPRequest) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	resp, err := r.Client.Do(r.Request)
-	util.KillPipelineIfErr(err, killChan, ctx)
-	if resp != nil && resp.Body != nil {
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
+	if resp.Body != nil {
 		dd, err := ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
-		util.KillPipelineIfErr(err, killChan, ctx)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
 		outputChan <- dd
 	}
 }
@@ -1,6 +1,7 @@
 package processors_test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -11,7 +12,7 @@ import (
 	"github.com/rhansen2/ratchet/processors"
 )
 
-func ExampleGetRequest() {
+func ExampleHTTPRequest() {
 	logger.LogLevel = logger.LevelSilent
 
 	getGoogle, err := processors.NewHTTPRequest("GET", "http://www.google.com", nil)
@@ -36,7 +37,7 @@ func ExampleGetRequest() {
 		return data.JSON(output)
 	})
 	stdout := processors.NewIoWriter(os.Stdout)
-	pipeline := ratchet.NewPipeline(getGoogle, checkHTML, stdout)
+	pipeline := ratchet.NewPipeline(context.Background(), nil, getGoogle, checkHTML, stdout)
 
 	err = <-pipeline.Run()
 
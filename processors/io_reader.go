@@ -11,6 +11,10 @@ import (
 )
 
 // IoReader wraps an io.Reader and reads it.
+//
+// IoReader is a ratchet.Source, so it's typically used in the first stage
+// of a Pipeline. In later stages, it reads whatever is left of the io.Reader
+// for every payload received.
 type IoReader struct {
 	Reader     io.Reader
 	LineByLine bool // defaults to true
@@ -23,15 +27,26 @@ func NewIoReader(reader io.Reader) *IoReader {
 	return &IoReader{Reader: reader, LineByLine: true, BufferSize: 1024}
 }
 
-// ProcessData overwrites the reader if the content is Gzipped, then defers to ForEachData
+// ProcessData calls Start, ignoring the data received.
 func (r *IoReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.Start(outputChan, killChan, ctx)
+}
+
+// Start overwrites the reader if the content is Gzipped, then defers to ForEachData
+func (r *IoReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	if r.Gzipped {
 		gzReader, err := gzip.NewReader(r.Reader)
-		util.KillPipelineIfErr(err, killChan, ctx)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
 		r.Reader = gzReader
 	}
 	r.ForEachData(killChan, func(d data.JSON) {
-		outputChan <- d
+		select {
+		case outputChan <- d:
+		case <-ctx.Done():
+		}
 	}, ctx)
 }
 
@@ -40,31 +55,33 @@ func (r *IoReader) Finish(outputChan chan data.JSON, killChan chan error, ctx co
 }
 
 // ForEachData either reads by line or by buffered stream, sending the data
-// back to the anonymous func that ultimately shoves it onto the outputChan
+// back to the anonymous func that ultimately shoves it onto the outputChan.
+// Reading stops early once ctx is done.
 func (r *IoReader) ForEachData(killChan chan error, foo func(d data.JSON), ctx context.Context) {
 	if r.LineByLine {
 		r.scanLines(killChan, foo, ctx)
 	} else {
-		r.bufferedRead(killChan, foo)
+		r.bufferedRead(killChan, foo, ctx)
 	}
 }
 
 func (r *IoReader) scanLines(killChan chan error, forEach func(d data.JSON), ctx context.Context) {
 	scanner := bufio.NewScanner(r.Reader)
-	for scanner.Scan() {
+	for scanner.Scan() && ctx.Err() == nil {
 		forEach(data.JSON(scanner.Text()))
 	}
 	err := scanner.Err()
 	util.KillPipelineIfErr(err, killChan, ctx)
 }
 
-func (r *IoReader) bufferedRead(killChan chan error, forEach func(d data.JSON)) {
+func (r *IoReader) bufferedRead(killChan chan error, forEach func(d data.JSON), ctx context.Context) {
 	reader := bufio.NewReader(r.Reader)
 	d := make([]byte, r.BufferSize)
-	for {
+	for ctx.Err() == nil {
 		n, err := reader.Read(d)
 		if err != nil && err != io.EOF {
-			killChan <- err
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
 		}
 		if n == 0 {
 			break
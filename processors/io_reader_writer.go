@@ -27,6 +27,11 @@ func NewIoReaderWriter(reader io.Reader, writer io.Writer) *IoReaderWriter {
 	return &r
 }
 
+// Start reads the io.Reader, as ProcessData does.
+func (r *IoReaderWriter) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.ProcessData(nil, outputChan, killChan, ctx)
+}
+
 // ProcessData grabs data from IoReader.ForEachData, then sends it to IoWriter.ProcessData in addition
 // to sending it upstream on the outputChan
 func (r *IoReaderWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
@@ -0,0 +1,249 @@
+package processors
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// LDAPReader runs an LDAP search (e.g. against Active Directory) and sends
+// each entry found as a JSON object, such as:
+//
+//	{"dn": "CN=Ann,OU=Staff,DC=example,DC=com", "cn": "Ann", "memberOf": ["CN=HR,..."]}
+//
+// Attributes with a single value are sent as a string, and attributes with
+// multiple values (or listed in MultiValued, so they're always consistent)
+// as a slice of strings. Attributes listed in BinaryAttributes are base64
+// encoded, except that Active Directory's objectGUID and objectSid are always
+// converted to their usual string forms.
+//
+// Searches are paged (with PageSize entries per page) so large directories
+// are sent as they're read. Set PageSize to 0 to disable paging for servers
+// that don't support it.
+//
+// LDAPReader is a ratchet.Source, so it's typically used in the first stage
+// of a Pipeline. In later stages, it runs the search for every payload received.
+type LDAPReader struct {
+	URL              string // e.g. "ldaps://dc.example.com" or "ldap://localhost:389".
+	BindDN           string // Leave empty for an anonymous search.
+	BindPassword     string
+	StartTLS         bool          // Upgrade an ldap:// connection with StartTLS.
+	TLSConfig        *tls.Config   // Default verifies the certificate against the host in URL.
+	Timeout          time.Duration // Per-request timeout, default is 1m.
+	BaseDN           string
+	Filter           string // Default is "(objectClass=*)".
+	Attributes       []string
+	Scope            int // ldap.ScopeWholeSubtree (the default), ldap.ScopeSingleLevel, or ldap.ScopeBaseObject.
+	SizeLimit        int
+	PageSize         int // Default is 500.
+	MultiValued      []string
+	BinaryAttributes []string
+	conn             *ldap.Conn
+}
+
+// NewLDAPReader returns a new LDAPReader searching baseDN for the entries
+// matching filter, sending the given attributes (or all, if none are given).
+func NewLDAPReader(url, baseDN, filter string, attributes ...string) *LDAPReader {
+	return &LDAPReader{
+		URL:        url,
+		Timeout:    time.Minute,
+		BaseDN:     baseDN,
+		Filter:     filter,
+		Attributes: attributes,
+		Scope:      ldap.ScopeWholeSubtree,
+		PageSize:   500,
+	}
+}
+
+// NewLDAPReaderByConn returns a new LDAPReader using an existing (bound)
+// connection, which isn't closed by the LDAPReader.
+func NewLDAPReaderByConn(conn *ldap.Conn, baseDN, filter string, attributes ...string) *LDAPReader {
+	r := NewLDAPReader("", baseDN, filter, attributes...)
+	r.conn = conn
+	return r
+}
+
+// ProcessData runs the search, see Start.
+func (r *LDAPReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.Start(outputChan, killChan, ctx)
+}
+
+// Start runs the search, sending each entry on to outputChan.
+func (r *LDAPReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	conn := r.conn
+	if conn == nil {
+		var err error
+		conn, err = r.connect()
+		util.KillPipelineIfErr(err, killChan, ctx)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+	}
+	util.KillPipelineIfErr(r.search(conn, outputChan, ctx), killChan, ctx)
+}
+
+// Finish - see interface for documentation.
+func (r *LDAPReader) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (r *LDAPReader) String() string {
+	return "LDAPReader"
+}
+
+func (r *LDAPReader) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(r.URL, ldap.DialWithTLSConfig(r.TLSConfig))
+	if err != nil {
+		return nil, err
+	}
+	if r.Timeout > 0 {
+		conn.SetTimeout(r.Timeout)
+	}
+	if r.StartTLS {
+		if err := conn.StartTLS(r.tlsConfig()); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.BindDN != "" {
+		err = conn.Bind(r.BindDN, r.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// tlsConfig returns the TLSConfig, or if it's nil, a config verifying the
+// server's certificate against the host in URL. Unlike tls.Dial (used for
+// ldaps:// URLs), StartTLS can't infer the server name.
+func (r *LDAPReader) tlsConfig() *tls.Config {
+	if r.TLSConfig != nil {
+		return r.TLSConfig
+	}
+	config := &tls.Config{}
+	if u, err := url.Parse(r.URL); err == nil {
+		config.ServerName = u.Hostname()
+	}
+	return config
+}
+
+func (r *LDAPReader) search(conn *ldap.Conn, outputChan chan data.JSON, ctx context.Context) error {
+	filter := r.Filter
+	if filter == "" {
+		filter = "(objectClass=*)"
+	}
+	req := ldap.NewSearchRequest(r.BaseDN, r.Scope, ldap.NeverDerefAliases, r.SizeLimit, 0, false, filter, r.Attributes, nil)
+	var paging *ldap.ControlPaging
+	if r.PageSize > 0 {
+		paging = ldap.NewControlPaging(uint32(r.PageSize))
+		req.Controls = []ldap.Control{paging}
+	}
+
+	sent := 0
+	for {
+		result, err := conn.Search(req)
+		if err != nil {
+			return err
+		}
+		for _, entry := range result.Entries {
+			d, err := data.NewJSON(r.entryObject(entry))
+			if err != nil {
+				return err
+			}
+			select {
+			case outputChan <- d:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		sent += len(result.Entries)
+		logger.Info("LDAPReader: sent", sent, "entries")
+
+		if paging == nil {
+			return nil
+		}
+		control, ok := ldap.FindControl(result.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		if !ok || len(control.Cookie) == 0 {
+			return nil
+		}
+		paging.SetCookie(control.Cookie)
+	}
+}
+
+func (r *LDAPReader) entryObject(entry *ldap.Entry) map[string]interface{} {
+	object := map[string]interface{}{"dn": entry.DN}
+	for _, attr := range entry.Attributes {
+		values := attr.Values
+		switch {
+		case strings.EqualFold(attr.Name, "objectGUID"):
+			values = convertValues(attr.ByteValues, formatGUID)
+		case strings.EqualFold(attr.Name, "objectSid"):
+			values = convertValues(attr.ByteValues, formatSID)
+		case containsFold(r.BinaryAttributes, attr.Name):
+			values = convertValues(attr.ByteValues, base64.StdEncoding.EncodeToString)
+		}
+		if len(values) == 1 && !containsFold(r.MultiValued, attr.Name) {
+			object[attr.Name] = values[0]
+		} else {
+			object[attr.Name] = values
+		}
+	}
+	return object
+}
+
+func convertValues(values [][]byte, convert func([]byte) string) []string {
+	converted := make([]string, len(values))
+	for i, v := range values {
+		converted[i] = convert(v)
+	}
+	return converted
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatGUID formats an Active Directory objectGUID, whose first three
+// fields are little-endian, e.g. "7b2e9a6c-3d1f-4e0a-9c8b-112233445566".
+func formatGUID(b []byte) string {
+	if len(b) != 16 {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]), binary.LittleEndian.Uint16(b[4:6]), binary.LittleEndian.Uint16(b[6:8]), b[8:10], b[10:])
+}
+
+// formatSID formats an Active Directory objectSid, e.g. "S-1-5-21-1004336348-1177238915-682003330-512".
+func formatSID(b []byte) string {
+	if len(b) < 8 || len(b) != 8+4*int(b[1]) {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	authority := uint64(0)
+	for _, v := range b[2:8] {
+		authority = authority<<8 | uint64(v)
+	}
+	sid := fmt.Sprintf("S-%d-%d", b[0], authority)
+	for i := 8; i < len(b); i += 4 {
+		sid += fmt.Sprintf("-%d", binary.LittleEndian.Uint32(b[i:i+4]))
+	}
+	return sid
+}
@@ -0,0 +1,81 @@
+package processors
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestLDAPReaderTLSConfig(t *testing.T) {
+	tests := []struct {
+		url        string
+		serverName string
+	}{
+		{url: "ldap://dc.example.com", serverName: "dc.example.com"},
+		{url: "ldap://dc.example.com:389", serverName: "dc.example.com"},
+		{url: "ldap://[::1]:389", serverName: "::1"},
+	}
+	for _, tt := range tests {
+		r := NewLDAPReader(tt.url, "DC=example,DC=com", "")
+		if got := r.tlsConfig(); got.ServerName != tt.serverName || got.InsecureSkipVerify {
+			t.Errorf("%v: got ServerName %q (InsecureSkipVerify %v), want %q", tt.url, got.ServerName, got.InsecureSkipVerify, tt.serverName)
+		}
+	}
+
+	r := NewLDAPReader("ldap://dc.example.com", "DC=example,DC=com", "")
+	r.TLSConfig = &tls.Config{ServerName: "other.example.com"}
+	if got := r.tlsConfig(); got != r.TLSConfig {
+		t.Errorf("got config %+v, want the TLSConfig set", got)
+	}
+}
+
+func TestFormatGUID(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want string
+	}{
+		// The first three groups are stored little-endian, the rest as is.
+		{
+			b:    []byte{0xff, 0x19, 0x96, 0x6f, 0x86, 0x8b, 0x11, 0xd0, 0xb4, 0x2d, 0x00, 0xc0, 0x4f, 0xc9, 0x64, 0xff},
+			want: "6f9619ff-8b86-d011-b42d-00c04fc964ff",
+		},
+		{
+			b:    []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			want: "04030201-0605-0807-090a-0b0c0d0e0f10",
+		},
+		{b: []byte{0x01, 0x02, 0x03}, want: "AQID"},
+	}
+	for _, tt := range tests {
+		if got := formatGUID(tt.b); got != tt.want {
+			t.Errorf("formatGUID(% x) = %q, want %q", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSID(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want string
+	}{
+		// BUILTIN\Administrators.
+		{
+			b:    []byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x20, 0x00, 0x00, 0x00, 0x20, 0x02, 0x00, 0x00},
+			want: "S-1-5-32-544",
+		},
+		// A domain's Domain Admins group.
+		{
+			b: []byte{0x01, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x15, 0x00, 0x00, 0x00, 0xdc, 0xf4, 0xdc, 0x3b,
+				0x83, 0x3d, 0x2b, 0x46, 0x82, 0x8b, 0xa6, 0x28, 0x00, 0x02, 0x00, 0x00},
+			want: "S-1-5-21-1004336348-1177238915-682003330-512",
+		},
+		// Everyone, with no sub-authority beyond the identifier authority.
+		{b: []byte{0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, want: "S-1-1-0"},
+		// The sub-authority count doesn't match the length.
+		{b: []byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x20, 0x00, 0x00, 0x00}, want: "AQIAAAAAAAUgAAAA"},
+		{b: []byte{0x01}, want: "AQ=="},
+	}
+	for _, tt := range tests {
+		if got := formatSID(tt.b); got != tt.want {
+			t.Errorf("formatSID(% x) = %q, want %q", tt.b, got, tt.want)
+		}
+	}
+}
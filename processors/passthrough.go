@@ -1,6 +1,10 @@
 package processors
 
-import "github.com/rhansen2/ratchet/data"
+import (
+	"context"
+
+	"github.com/rhansen2/ratchet/data"
+)
 
 // Passthrough simply passes the data on to the next stage.
 // We have to set a placeholder field - if we leave this as an empty struct we get some properties
@@ -15,13 +19,17 @@ func NewPassthrough() *Passthrough {
 	return &Passthrough{}
 }
 
-// ProcessData blindly sends whatever it receives to the outputChan
-func (r *Passthrough) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error) {
-	outputChan <- d
+// ProcessData blindly sends whatever it receives to the outputChan,
+// giving up if ctx is done (e.g. when a Pipeline's shutdown times out).
+func (r *Passthrough) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	select {
+	case outputChan <- d:
+	case <-ctx.Done():
+	}
 }
 
 // Finish - see interface for documentation.
-func (r *Passthrough) Finish(outputChan chan data.JSON, killChan chan error) {
+func (r *Passthrough) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 }
 
 func (r *Passthrough) String() string {
@@ -0,0 +1,105 @@
+package processors
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// Recorder tees every payload it receives to a recording (see
+// util.RecordingWriter), noting when it was received, and sends the
+// payload on unchanged. Place a Recorder at any point in a Pipeline to
+// capture the data passing that point, which a Replayer can then send
+// again, e.g. to reproduce an incident without the upstream systems.
+//
+// Each payload is flushed to the Writer as it's recorded, so a recording
+// is usable even if the Pipeline is killed (a Replayer sends the payloads
+// recorded before a crash, including from a compressed recording). Set
+// Compress to gzip the recording.
+type Recorder struct {
+	Writer    io.Writer
+	Compress  bool
+	recording *util.RecordingWriter
+	gz        *gzip.Writer
+	sync.Mutex
+}
+
+// NewRecorder returns a new Recorder writing to the given io.Writer.
+func NewRecorder(writer io.Writer) *Recorder {
+	return &Recorder{Writer: writer}
+}
+
+// ProcessData records d and then sends it on.
+func (r *Recorder) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	err := r.record(d)
+	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		return
+	}
+	select {
+	case outputChan <- d:
+	case <-ctx.Done():
+	}
+}
+
+// Finish completes the recording. If no payloads were received, the
+// recording is still written, so it can be replayed (sending nothing).
+func (r *Recorder) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.Lock()
+	defer r.Unlock()
+	if r.recording == nil {
+		err := r.start(time.Now())
+		if err == nil {
+			err = r.recording.Flush()
+		}
+		util.KillPipelineIfErr(err, killChan, ctx)
+		if err != nil {
+			return
+		}
+	}
+	if r.gz != nil {
+		util.KillPipelineIfErr(r.gz.Close(), killChan, ctx)
+	}
+}
+
+func (r *Recorder) String() string {
+	return "Recorder"
+}
+
+func (r *Recorder) record(d data.JSON) error {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	if r.recording == nil {
+		if err := r.start(now); err != nil {
+			return err
+		}
+	}
+	if err := r.recording.Write(now, d); err != nil {
+		return err
+	}
+	if err := r.recording.Flush(); err != nil {
+		return err
+	}
+	if r.gz != nil {
+		return r.gz.Flush()
+	}
+	return nil
+}
+
+// start writes the header of a recording started at now.
+func (r *Recorder) start(now time.Time) error {
+	w := r.Writer
+	if r.Compress {
+		r.gz = gzip.NewWriter(w)
+		w = r.gz
+	}
+	var err error
+	r.recording, err = util.NewRecordingWriter(w, now)
+	return err
+}
@@ -0,0 +1,154 @@
+package processors_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+	"github.com/rhansen2/ratchet/util"
+)
+
+func TestRecorderReplayer(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		recorder := processors.NewRecorder(&buf)
+		recorder.Compress = compress
+		r := ratchettest.Process(t, recorder, data.JSON(`{"a":1}`), data.JSON(`[2,3]`))
+		r.AssertNoErrors(t)
+		r.AssertOutputs(t, `{"a":1}`, `[2,3]`)
+
+		r = ratchettest.Process(t, processors.NewReplayer(&buf))
+		r.AssertNoErrors(t)
+		r.AssertOutputs(t, `{"a":1}`, `[2,3]`)
+	}
+}
+
+func TestRecorderNoPayloads(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		recorder := processors.NewRecorder(&buf)
+		recorder.Compress = compress
+		recorder.Finish(nil, nil, context.Background())
+
+		r := ratchettest.Process(t, processors.NewReplayer(&buf))
+		r.AssertNoErrors(t)
+		r.AssertOutputs(t)
+	}
+}
+
+// TestReplayerTruncated replays a compressed recording whose Recorder never
+// finished, with a partial payload at the end.
+func TestReplayerTruncated(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	var buf bytes.Buffer
+	recorder := processors.NewRecorder(&buf)
+	recorder.Compress = true
+	outputChan := make(chan data.JSON, 2)
+	recorder.ProcessData(data.JSON(`{"a":1}`), outputChan, nil, context.Background())
+	recorder.ProcessData(data.JSON(`{"b":2}`), outputChan, nil, context.Background())
+
+	for _, recording := range [][]byte{buf.Bytes(), buf.Bytes()[:buf.Len()-3]} {
+		r := ratchettest.Process(t, processors.NewReplayer(bytes.NewReader(recording)))
+		r.AssertNoErrors(t)
+		if len(r.Outputs) == 0 || string(r.Outputs[0]) != `{"a":1}` {
+			t.Errorf("got outputs %q, want the payloads recorded", r.Outputs)
+		}
+	}
+}
+
+// TestReplayerLaterStage replays a recording for each payload received,
+// as a Replayer does in a later stage of a Pipeline.
+func TestReplayerLaterStage(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	var buf bytes.Buffer
+	recorder := processors.NewRecorder(&buf)
+	ratchettest.Process(t, recorder, data.JSON(`1`)).AssertNoErrors(t)
+
+	replay := func(replayer *processors.Replayer) ([]data.JSON, []error) {
+		outputChan := make(chan data.JSON, 2)
+		killChan := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			replayer.ProcessData(data.JSON(`{}`), outputChan, killChan, context.Background())
+		}
+		close(outputChan)
+		close(killChan)
+		var outputs []data.JSON
+		for d := range outputChan {
+			outputs = append(outputs, d)
+		}
+		var errs []error
+		for err := range killChan {
+			errs = append(errs, err)
+		}
+		return outputs, errs
+	}
+
+	// A bytes.Reader is rewound, so the recording is replayed twice.
+	outputs, errs := replay(processors.NewReplayer(bytes.NewReader(buf.Bytes())))
+	if len(outputs) != 2 || len(errs) != 0 {
+		t.Errorf("got outputs %q and errors %v, want the recording replayed twice", outputs, errs)
+	}
+
+	// A bytes.Buffer can't be, so it's only replayed once.
+	outputs, errs = replay(processors.NewReplayer(bytes.NewBuffer(buf.Bytes())))
+	if len(outputs) != 1 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "already been replayed") {
+		t.Errorf("got outputs %q and errors %v, want the recording replayed once", outputs, errs)
+	}
+}
+
+func TestRecorderCancelled(t *testing.T) {
+	recorder := processors.NewRecorder(ioutil.Discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		// Nothing receives from the outputChan, so this returns only if
+		// ProcessData gives up sending when ctx is done.
+		recorder.ProcessData(data.JSON(`{}`), make(chan data.JSON), make(chan error, 1), ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ratchettest.Timeout):
+		t.Fatal("ProcessData didn't return after ctx was done")
+	}
+}
+
+func TestReplayerSpeed(t *testing.T) {
+	start := time.Now()
+	var buf bytes.Buffer
+	w, err := util.NewRecordingWriter(&buf, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(start.Add(100*time.Millisecond), []byte(`1`))
+	w.Write(start.Add(300*time.Millisecond), []byte(`2`))
+	w.Flush()
+
+	tests := []struct {
+		speed    float64
+		min, max time.Duration
+	}{
+		{speed: 0, min: 0, max: 100 * time.Millisecond},
+		{speed: 1, min: 300 * time.Millisecond, max: 2 * time.Second},
+		{speed: 3, min: 100 * time.Millisecond, max: 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		replayer := processors.NewReplayer(bytes.NewReader(buf.Bytes()))
+		replayer.Speed = tt.speed
+		began := time.Now()
+		r := ratchettest.Process(t, replayer)
+		took := time.Since(began)
+		r.AssertOutputs(t, `1`, `2`)
+		if took < tt.min || took > tt.max {
+			t.Errorf("Speed %v: replay took %v, want between %v and %v", tt.speed, took, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package processors
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisLookup is a LookupBackend reading each key from Redis, prefixed by
+// KeyPrefix (e.g. "customer:"). By default the key's value must be a JSON
+// object, set Hash to instead read a hash's fields (with HGETALL).
+type RedisLookup struct {
+	KeyPrefix string
+	Hash      bool
+	pool      *redis.Pool
+}
+
+// NewRedisLookup returns a new RedisLookup connecting to the Redis server
+// at the given URL, e.g. "redis://localhost:6379/0".
+func NewRedisLookup(url string) *RedisLookup {
+	return NewRedisLookupByPool(newRedisPool(url))
+}
+
+func newRedisPool(url string) *redis.Pool {
+	return &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url)
+		},
+		MaxIdle:     8,
+		IdleTimeout: 5 * time.Minute,
+	}
+}
+
+// NewRedisLookupByPool returns a new RedisLookup using an existing connection pool.
+func NewRedisLookupByPool(pool *redis.Pool) *RedisLookup {
+	return &RedisLookup{pool: pool}
+}
+
+// Lookup reads key from Redis.
+func (l *RedisLookup) Lookup(key string, ctx context.Context) (map[string]interface{}, error) {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if l.Hash {
+		fields, err := redis.StringMap(conn.Do("HGETALL", l.KeyPrefix+key))
+		if err != nil || len(fields) == 0 {
+			return nil, err
+		}
+		object := make(map[string]interface{}, len(fields))
+		for f, v := range fields {
+			object[f] = v
+		}
+		return object, nil
+	}
+
+	d, err := redis.Bytes(conn.Do("GET", l.KeyPrefix+key))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeObject(d)
+}
+
+// Close closes the connection pool.
+func (l *RedisLookup) Close() error {
+	return l.pool.Close()
+}
@@ -0,0 +1,119 @@
+package processors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// RedshiftWriter bulk loads data.JSON into a Redshift table. Data is staged
+// in S3 as gzip compressed CSV files (see WarehouseStaging) and loaded with
+// COPY, which is much faster than INSERTing rows with a SQLWriter.
+//
+// Staged files are written under Prefix in Bucket, and left in place after
+// loading. COPY is authorized with IAMRole if set, or else with the AWS
+// credentials used to upload. Setting IAMRole is preferred, since otherwise
+// the credentials are part of the COPY statement sent to Redshift (they're
+// never logged by RedshiftWriter).
+//
+// Batches are always staged as CSV, since Redshift loads Parquet columns by
+// position rather than by name, which can't load the subset of a table's
+// columns present in a batch.
+type RedshiftWriter struct {
+	WarehouseStaging // embeds WarehouseStaging
+	IAMRole          string
+	Prefix           string
+	db               *sql.DB
+	upload           func(bucket, key string, body io.Reader) error
+	awsID            string
+	awsSecret        string
+	region           string
+	bucket           string
+}
+
+// NewRedshiftWriter returns a new RedshiftWriter loading tableName, staging files in the given bucket.
+func NewRedshiftWriter(db *sql.DB, tableName, awsID, awsSecret, awsRegion, bucket string) *RedshiftWriter {
+	creds := credentials.NewStaticCredentials(awsID, awsSecret, "")
+	return &RedshiftWriter{
+		WarehouseStaging: WarehouseStaging{TableName: tableName},
+		db:               db,
+		upload:           s3Uploader(aws.NewConfig().WithRegion(awsRegion).WithCredentials(creds)),
+		awsID:            awsID,
+		awsSecret:        awsSecret,
+		region:           awsRegion,
+		bucket:           bucket,
+	}
+}
+
+// ProcessData buffers the data, loading a batch once BatchSize rows are buffered.
+func (w *RedshiftWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	if err := w.add(d); err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
+	if w.full() {
+		util.KillPipelineIfErr(w.load(ctx), killChan, ctx)
+	}
+}
+
+// Finish loads any remaining buffered data.
+func (w *RedshiftWriter) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	util.KillPipelineIfErr(w.load(ctx), killChan, ctx)
+}
+
+func (w *RedshiftWriter) String() string {
+	return "RedshiftWriter"
+}
+
+var redshiftTypes = warehouseTypes{
+	boolean: "BOOLEAN",
+	integer: "BIGINT",
+	float:   "DOUBLE PRECISION",
+	text:    "VARCHAR(65535)",
+}
+
+func (w *RedshiftWriter) load(ctx context.Context) error {
+	if err := w.createTable(ctx, w.db, redshiftTypes); err != nil {
+		w.objects = nil
+		return err
+	}
+	batch, err := w.stage()
+	if err != nil || batch == nil {
+		return err
+	}
+
+	key := path.Join(w.Prefix, batch.name)
+	logger.Info("RedshiftWriter: staging", batch.rows, "rows to", key)
+	if err := w.upload(w.bucket, key, batch.file); err != nil {
+		return err
+	}
+
+	logger.Info("RedshiftWriter: loading", key, "into", w.TableName)
+	return warehouseExec(ctx, w.db, w.copySQL(batch, key))
+}
+
+// copySQL returns the COPY statement loading the batch staged at key.
+func (w *RedshiftWriter) copySQL(batch *stagedBatch, key string) string {
+	auth := "IAM_ROLE " + sqlString(w.IAMRole)
+	if w.IAMRole == "" {
+		auth = fmt.Sprintf("ACCESS_KEY_ID %v SECRET_ACCESS_KEY %v", sqlString(w.awsID), sqlString(w.awsSecret))
+	}
+	return fmt.Sprintf(`COPY %v (%v) FROM %v %v REGION %v FORMAT AS CSV GZIP NULL AS '\\N' TIMEFORMAT 'auto'`,
+		quoteTableName(w.TableName), quoteIdents(batch.columns), sqlString("s3://"+w.bucket+"/"+key), auth, sqlString(w.region))
+}
+
+// s3Uploader returns a func uploading files to S3 with the given config.
+func s3Uploader(config *aws.Config) func(bucket, key string, body io.Reader) error {
+	return func(bucket, key string, body io.Reader) error {
+		_, err := util.UploadS3Object(config, bucket, key, body)
+		return err
+	}
+}
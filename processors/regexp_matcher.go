@@ -27,7 +27,10 @@ func NewRegexpMatcher(pattern string) *RegexpMatcher {
 // ProcessData sends the data it receives to the outputChan only if it matches the supplied regex
 func (r *RegexpMatcher) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	matches, err := regexp.Match(r.pattern, d)
-	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
 	if r.DebugLog {
 		logger.Debug("RegexpMatcher: checking if", string(d), "matches pattern", r.pattern, ". MATCH=", matches)
 	}
@@ -0,0 +1,138 @@
+package processors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/data"
+)
+
+// Factory constructs a DataProcessor from the given Params. See Register.
+type Factory func(params Params) (ratchet.DataProcessor, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// registration is a registered DataProcessor. The built-in processors
+// also have a builtin func, which opens files and databases through a
+// resources so they can be closed (see Builder) or checked (see Validate).
+type registration struct {
+	factory Factory
+	builtin func(params Params, res resources) (ratchet.DataProcessor, error)
+}
+
+// Register makes a DataProcessor available by name, so it can be constructed
+// with New (for example, from a ratchet.LayoutConfig). All of the built-in
+// processors are registered under snake_case names, e.g. "sftp_reader".
+// If Register is called twice with the same name, it panics.
+func Register(name string, factory Factory) {
+	if factory == nil {
+		panic("processors: Register factory is nil")
+	}
+	register(name, registration{factory: factory})
+}
+
+func registerBuiltin(name string, build func(params Params, res resources) (ratchet.DataProcessor, error)) {
+	register(name, registration{
+		factory: func(params Params) (ratchet.DataProcessor, error) {
+			return build(params, &Builder{})
+		},
+		builtin: build,
+	})
+}
+
+func register(name string, r registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("processors: Register called twice for " + name)
+	}
+	registry[name] = r
+}
+
+// Lookup returns the Factory registered under name, if any.
+func Lookup(name string) (Factory, bool) {
+	r, ok := lookup(name)
+	return r.factory, ok
+}
+
+func lookup(name string) (registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Registered returns the sorted names of all registered DataProcessors.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New constructs the DataProcessor registered under name from the given
+// parameters. It can be passed directly to ratchet.LoadLayout as a
+// ratchet.ProcessorBuilder. Any files or databases opened by the built-in
+// processors are left open, use a Builder to close them once done.
+func New(name string, params map[string]interface{}) (ratchet.DataProcessor, error) {
+	f, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("processors: unknown processor %q", name)
+	}
+	if params == nil {
+		params = Params{}
+	}
+	return f(params)
+}
+
+// Validate checks that a DataProcessor could be constructed by New with the
+// given parameters, without opening any files, databases, or connections.
+// The parameters of the built-in processors are fully checked (missing,
+// unknown values, or values of the wrong type), while for other registered
+// processors only the name is checked.
+func Validate(name string, params map[string]interface{}) error {
+	r, ok := lookup(name)
+	if !ok {
+		return fmt.Errorf("processors: unknown processor %q", name)
+	}
+	if r.builtin == nil {
+		return nil
+	}
+	if params == nil {
+		params = Params{}
+	}
+	_, err := r.builtin(params, checkResources{})
+	return err
+}
+
+// Params holds the parameters used to construct a registered DataProcessor.
+type Params map[string]interface{}
+
+// Require returns an error if any of the given parameters are missing.
+func (p Params) Require(names ...string) error {
+	for _, name := range names {
+		if _, ok := p[name]; !ok {
+			return fmt.Errorf("missing required param %q", name)
+		}
+	}
+	return nil
+}
+
+// Decode fills in the fields of v (a pointer to a struct) from the
+// parameters, using the same rules as encoding/json.
+func (p Params) Decode(v interface{}) error {
+	d, err := data.NewJSON(p)
+	if err != nil {
+		return err
+	}
+	return data.ParseJSON(d, v)
+}
@@ -0,0 +1,109 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// Replayer sends the payloads in a recording made by a Recorder, in the
+// order they were recorded. By default they're sent as fast as possible,
+// set Speed to 1 to keep the original pacing between payloads (or to 2 for
+// twice as fast, 0.5 for half speed, etc).
+//
+// A recording cut short (e.g. because the recording Pipeline crashed) is
+// replayed up to the last complete payload, logging a warning rather than
+// killing the Pipeline.
+//
+// Replayer is a ratchet.Source, so should be used in the first stage of a
+// Pipeline, in place of the stages that were upstream of the Recorder. In a
+// later stage the recording is replayed for each payload received, which
+// needs a Reader that can be rewound (an io.Seeker, such as an *os.File);
+// otherwise replaying it again sends an error.
+type Replayer struct {
+	Reader   io.Reader
+	Speed    float64
+	replayed bool
+}
+
+// NewReplayer returns a new Replayer reading the recording from the given io.Reader.
+func NewReplayer(reader io.Reader) *Replayer {
+	return &Replayer{Reader: reader}
+}
+
+// ProcessData replays the recording, see Start.
+func (r *Replayer) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.Start(outputChan, killChan, ctx)
+}
+
+// Start sends each payload in the recording on to outputChan.
+func (r *Replayer) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	if err := r.rewind(); err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
+	recording, err := util.NewRecordingReader(r.Reader)
+	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		return
+	}
+
+	replayed := 0
+	last := recording.Start()
+	for {
+		t, d, err := recording.Read()
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			logger.ErrorWithoutTrace("Replayer: recording is truncated after", replayed, "payloads, replaying what was recorded")
+			break
+		}
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
+		if r.Speed > 0 {
+			select {
+			case <-time.After(time.Duration(float64(t.Sub(last)) / r.Speed)):
+			case <-ctx.Done():
+				return
+			}
+		}
+		last = t
+		select {
+		case outputChan <- d:
+		case <-ctx.Done():
+			return
+		}
+		replayed++
+	}
+	logger.Info("Replayer: replayed", replayed, "payloads recorded from", recording.Start())
+}
+
+// rewind seeks back to the start of the recording if it's been replayed before.
+func (r *Replayer) rewind() error {
+	if !r.replayed {
+		r.replayed = true
+		return nil
+	}
+	seeker, ok := r.Reader.(io.Seeker)
+	if !ok {
+		return errors.New("Replayer: the recording has already been replayed, and its Reader can't be rewound")
+	}
+	_, err := seeker.Seek(0, io.SeekStart)
+	return err
+}
+
+// Finish - see interface for documentation.
+func (r *Replayer) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (r *Replayer) String() string {
+	return "Replayer"
+}
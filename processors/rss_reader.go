@@ -0,0 +1,117 @@
+package processors
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// RSSReader polls an RSS, Atom, or JSON feed and sends each new entry
+// downstream as a JSON object (see gofeed.Item for the fields). It's a
+// ratchet.Source, so should be used in the first stage of a Pipeline, and will
+// keep running until the Pipeline's context is cancelled. Set Once to only
+// read the feed a single time.
+//
+// Entries are identified by their GUID (or link or title, if there's no GUID) and
+// recorded in the Ledger after they've been sent, so each entry is only sent
+// once. Set Ledger to a util.NewFileLedger to remember entries across restarts.
+// Feeds usually list the newest entries first, so entries are sent in reverse
+// order to send the oldest first.
+type RSSReader struct {
+	URL      string
+	Interval time.Duration // How often to poll the feed, default is 5m.
+	Ledger   util.Ledger   // Defaults to an in-memory util.MemoryLedger.
+	Once     bool
+	Client   *http.Client
+}
+
+// NewRSSReader returns a new RSSReader polling the feed at url.
+func NewRSSReader(url string) *RSSReader {
+	return &RSSReader{
+		URL:      url,
+		Interval: 5 * time.Minute,
+		Ledger:   util.NewMemoryLedger(),
+		Client:   &http.Client{},
+	}
+}
+
+// ProcessData starts polling the feed, see Start.
+func (r *RSSReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.Start(outputChan, killChan, ctx)
+}
+
+// Start polls the feed, sending each new entry on to outputChan,
+// until ctx is done.
+func (r *RSSReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	parser := gofeed.NewParser()
+	parser.Client = r.Client
+	for {
+		err := r.poll(parser, outputChan, ctx)
+		if r.Once {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
+		if err != nil && ctx.Err() == nil {
+			// Feeds are often briefly unavailable, so log
+			// and try again on the next poll rather than halting.
+			logger.Error("RSSReader: error reading", r.URL, "-", err.Error())
+		}
+		timer := time.NewTimer(r.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Finish - see interface for documentation.
+func (r *RSSReader) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (r *RSSReader) String() string {
+	return "RSSReader"
+}
+
+func (r *RSSReader) poll(parser *gofeed.Parser, outputChan chan data.JSON, ctx context.Context) error {
+	feed, err := parser.ParseURLWithContext(r.URL, ctx)
+	if err != nil {
+		return err
+	}
+	for i := len(feed.Items) - 1; i >= 0; i-- {
+		item := feed.Items[i]
+		key := item.GUID
+		if key == "" {
+			key = item.Link
+		}
+		if key == "" {
+			key = item.Title
+		}
+		processed, err := r.Ledger.Processed(key)
+		if err != nil {
+			return err
+		}
+		if processed {
+			continue
+		}
+		d, err := data.NewJSON(item)
+		if err != nil {
+			return err
+		}
+		select {
+		case outputChan <- d:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := r.Ledger.MarkProcessed(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package processors_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// feedServer serves an RSS feed of the given items, newest first.
+type feedServer struct {
+	sync.Mutex
+	items []string
+}
+
+func (f *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	if f.items == nil {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title>`)
+	for i := len(f.items) - 1; i >= 0; i-- {
+		fmt.Fprint(w, f.items[i])
+	}
+	fmt.Fprint(w, `</channel></rss>`)
+}
+
+func (f *feedServer) add(item string) {
+	f.Lock()
+	defer f.Unlock()
+	f.items = append(f.items, item)
+}
+
+func titles(t *testing.T, result *ratchettest.Result) string {
+	t.Helper()
+	result.AssertNoErrors(t)
+	titles := []string{}
+	for _, d := range result.Outputs {
+		var item struct {
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal(d, &item); err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, item.Title)
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestRSSReader(t *testing.T) {
+	feed := &feedServer{}
+	feed.add(`<item><title>first</title><guid>1</guid></item>`)
+	feed.add(`<item><title>second</title><link>http://example.com/2</link></item>`)
+	server := httptest.NewServer(feed)
+	defer server.Close()
+
+	r := processors.NewRSSReader(server.URL)
+	r.Once = true
+	if got := titles(t, ratchettest.Process(t, r)); got != "first,second" {
+		t.Errorf("sent %v, want the oldest entry first", got)
+	}
+
+	// Only entries that haven't been sent are sent on the next poll,
+	// including entries identified by their link or title.
+	feed.add(`<item><title>third</title></item>`)
+	if got := titles(t, ratchettest.Process(t, r)); got != "third" {
+		t.Errorf("sent %v, want only the new entry", got)
+	}
+	if got := titles(t, ratchettest.Process(t, r)); got != "" {
+		t.Errorf("sent %v, want nothing new", got)
+	}
+}
+
+func TestRSSReaderLedger(t *testing.T) {
+	feed := &feedServer{}
+	feed.add(`<item><title>first</title><guid>1</guid></item>`)
+	server := httptest.NewServer(feed)
+	defer server.Close()
+
+	ledger := util.NewMemoryLedger()
+	ledger.MarkProcessed("1")
+	r := processors.NewRSSReader(server.URL)
+	r.Ledger = ledger
+	r.Once = true
+	if got := titles(t, ratchettest.Process(t, r)); got != "" {
+		t.Errorf("sent %v, which is already in the ledger", got)
+	}
+}
+
+func TestRSSReaderUnavailable(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	server := httptest.NewServer(&feedServer{})
+	defer server.Close()
+
+	r := processors.NewRSSReader(server.URL)
+	r.Once = true
+	result := ratchettest.Process(t, r)
+	result.AssertError(t, "503")
+	result.AssertOutputs(t)
+}
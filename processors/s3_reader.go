@@ -4,6 +4,9 @@ package processors
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -19,8 +22,15 @@ import (
 // prefix in your bucket.
 // S3Reader embeds an IoReeader, so it will support the same configuration
 // options as IoReader.
+//
+// For large objects, see ChunkedTransfer for chunked, resumable transfers
+// (using ranged GETs) and checksum verification.
+//
+// S3Reader is a ratchet.Source, so it's typically used in the first stage
+// of a Pipeline. In later stages, it reads the objects again for every payload received.
 type S3Reader struct {
 	IoReader            // embeds IoReader
+	ChunkedTransfer     // embeds ChunkedTransfer
 	bucket              string
 	object              string
 	prefix              string
@@ -49,28 +59,40 @@ func NewS3PrefixReader(awsID, awsSecret, awsRegion, bucket, prefix string) *S3Re
 	return r
 }
 
-// ProcessData reads an entire directory if a prefix is provided (sending each file in that
+// ProcessData calls Start, ignoring the data received.
+func (r *S3Reader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.Start(outputChan, killChan, ctx)
+}
+
+// Start reads an entire directory if a prefix is provided (sending each file in that
 // directory to outputChan), or just sends the single file to outputChan if a complete
 // file path is provided (not a prefix/directory).
 //
-// It optionally deletes all processed objects once the contents have been sent to outputChan
-func (r *S3Reader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+// It optionally deletes all processed objects once the contents have been sent to outputChan.
+// Objects are only deleted once every object has been sent, so nothing is deleted if the
+// pipeline is halted or shut down first.
+func (r *S3Reader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	if r.prefix != "" {
 		logger.Debug("S3Reader: process data for prefix", r.prefix)
 		objects, err := util.ListS3Objects(r.client, r.bucket, r.prefix)
 		logger.Debug("S3Reader: list =", objects)
-		util.KillPipelineIfErr(err, killChan, ctx)
-		for _, o := range objects {
-			obj, err := util.GetS3Object(r.client, r.bucket, o)
+		if err != nil {
 			util.KillPipelineIfErr(err, killChan, ctx)
-			r.processObject(obj, outputChan, killChan, ctx)
+			return
+		}
+		for _, o := range objects {
+			if err := r.sendObject(o, 0, outputChan, ctx); err != nil {
+				util.KillPipelineIfErr(err, killChan, ctx)
+				return
+			}
 			r.processedObjectKeys = append(r.processedObjectKeys, o)
 		}
 	} else {
 		logger.Debug("S3Reader: process data for object", r.object)
-		obj, err := util.GetS3Object(r.client, r.bucket, r.object)
-		util.KillPipelineIfErr(err, killChan, ctx)
-		r.processObject(obj, outputChan, killChan, ctx)
+		if err := r.sendObject(r.object, r.ResumeFrom, outputChan, ctx); err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
 		r.processedObjectKeys = append(r.processedObjectKeys, r.object)
 	}
 	if r.DeleteObjects {
@@ -83,11 +105,87 @@ func (r *S3Reader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan
 func (r *S3Reader) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 }
 
-func (r *S3Reader) processObject(obj *s3.GetObjectOutput, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+// sendObject sends the object with the given key, returning ctx.Err()
+// if ctx is done before it has been sent in full.
+func (r *S3Reader) sendObject(key string, resumeFrom int64, outputChan chan data.JSON, ctx context.Context) error {
+	if r.chunked() {
+		head, err := util.HeadS3Object(r.client, r.bucket, key)
+		if err != nil {
+			return err
+		}
+		obj := &remoteObject{
+			path:           key,
+			size:           aws.Int64Value(head.ContentLength),
+			version:        aws.StringValue(head.ETag),
+			resumeFrom:     resumeFrom,
+			reader:         &s3RangeReader{client: r.client, bucket: r.bucket, key: key},
+			serverChecksum: etagChecksum(key, aws.StringValue(head.ETag)),
+		}
+		return r.sendChunks(obj, outputChan, ctx)
+	}
+	obj, err := util.GetS3Object(r.client, r.bucket, key)
+	if err != nil {
+		return err
+	}
+	return r.processObject(key, obj, outputChan, ctx)
+}
+
+func (r *S3Reader) processObject(key string, obj *s3.GetObjectOutput, outputChan chan data.JSON, ctx context.Context) error {
+	defer obj.Body.Close()
+	reader, h, err := r.hashReader(obj.Body)
+	if err != nil {
+		return err
+	}
 	// Use IoReader for actual data handling
-	r.IoReader.Reader = obj.Body
-	r.IoReader.ProcessData(nil, outputChan, killChan, ctx)
-	obj.Body.Close()
+	errChan := make(chan error, 1)
+	r.IoReader.Reader = reader
+	r.IoReader.ProcessData(nil, outputChan, errChan, ctx)
+	select {
+	case err := <-errChan:
+		return err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ro := &remoteObject{path: key, size: aws.Int64Value(obj.ContentLength), serverChecksum: etagChecksum(key, aws.StringValue(obj.ETag))}
+	return r.complete(ro, h, 0, outputChan, ctx)
+}
+
+// s3RangeReader implements io.ReaderAt using ranged GETs, so that each
+// chunk of a ChunkedTransfer is its own request.
+type s3RangeReader struct {
+	client *s3.S3
+	bucket string
+	key    string
+}
+
+func (s *s3RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	obj, err := util.GetS3ObjectRange(s.client, s.bucket, s.key, off, off+int64(len(p))-1)
+	if err != nil {
+		return 0, err
+	}
+	defer obj.Body.Close()
+	n, err := io.ReadFull(obj.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// etagChecksum returns a func checking an object's ETag, which S3 only
+// sets to the object's md5 for objects that weren't uploaded in parts.
+func etagChecksum(key, etag string) func(string) (string, error) {
+	return func(algorithm string) (string, error) {
+		etag = strings.Trim(etag, `"`)
+		if algorithm != "md5" || etag == "" || strings.Contains(etag, "-") {
+			return "", fmt.Errorf("S3Reader: no %v checksum available to verify %v (ETag %q)", algorithm, key, etag)
+		}
+		return etag, nil
+	}
 }
 
 func (r *S3Reader) String() string {
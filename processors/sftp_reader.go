@@ -1,7 +1,13 @@
 package processors
 
 import (
+	"compress/gzip"
 	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
 
 	"github.com/pkg/sftp"
 	"github.com/rhansen2/ratchet/data"
@@ -14,15 +20,35 @@ import (
 //
 // To only send full paths (and not file contents), set FileNamesOnly to true.
 // If FileNamesOnly is set to true, DeleteObjects will be ignored.
+//
+// For large files, see ChunkedTransfer for chunked, resumable transfers
+// and checksum verification.
+//
+// The server's host key is verified, and the connection can be hardened with
+// timeouts and keepalives, using the embedded util.SftpOptions. If
+// MaxReconnects is set, a connection dropped during a walk is re-established
+// and the walk continues with the files that haven't been sent. A file
+// interrupted part way through is read again from the start, skipping the
+// payloads that were already sent (so the file mustn't change in between),
+// or resumed from its last chunk if it's being sent in chunks.
+//
+// SftpReader is a ratchet.Source, so it's typically used in the first stage
+// of a Pipeline. In later stages, it reads the path again for every payload received.
 type SftpReader struct {
-	IoReader      // embeds IoReader
-	parameters    *util.SftpParameters
-	client        *sftp.Client
-	DeleteObjects bool
-	Walk          bool
-	FileNamesOnly bool
-	initialized   bool
-	CloseOnFinish bool
+	IoReader         // embeds IoReader
+	ChunkedTransfer  // embeds ChunkedTransfer
+	util.SftpOptions // embeds SftpOptions
+	parameters       *util.SftpParameters
+	conn             *util.SftpConnection
+	client           *sftp.Client
+	DeleteObjects    bool
+	Walk             bool
+	FileNamesOnly    bool
+	initialized      bool
+	CloseOnFinish    bool
+	// partial counts the payloads sent from each file before its
+	// connection was dropped, so they aren't sent again on retry.
+	partial map[string]int
 }
 
 // NewSftpReader instantiates a new sftp reader, a connection to the remote server is delayed until data is recv'd by the reader
@@ -51,6 +77,7 @@ func NewSftpReader(server string, username string, path string, authMethods ...s
 func NewSftpReaderByClient(client *sftp.Client, path string) *SftpReader {
 	r := SftpReader{
 		parameters:    &util.SftpParameters{Path: path},
+		conn:          util.NewSftpConnectionByClient(client),
 		client:        client,
 		initialized:   true,
 		DeleteObjects: false,
@@ -61,15 +88,25 @@ func NewSftpReaderByClient(client *sftp.Client, path string) *SftpReader {
 	return &r
 }
 
-// ProcessData optionally walks through the tree to send each object separately, or sends the single
-// object upstream
+// ProcessData calls Start, ignoring the data received.
 func (r *SftpReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
-	r.ensureInitialized(killChan, ctx)
+	r.Start(outputChan, killChan, ctx)
+}
+
+// Start optionally walks through the tree to send each object separately, or sends the single
+// object upstream
+func (r *SftpReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	r.ensureInitialized()
+	var err error
 	if r.Walk {
-		r.walk(outputChan, killChan, ctx)
+		err = r.walk(outputChan, ctx)
 	} else {
-		r.sendObject(r.parameters.Path, outputChan, killChan, ctx)
+		err = r.conn.Retry(func(client *sftp.Client) error {
+			r.client = client
+			return r.sendObject(r.parameters.Path, outputChan, ctx)
+		})
 	}
+	util.KillPipelineIfErr(err, killChan, ctx)
 }
 
 // Finish optionally closes open references to the remote server
@@ -82,61 +119,167 @@ func (r *SftpReader) Finish(outputChan chan data.JSON, killChan chan error, ctx
 // CloseClient allows you to manually close the connection to the remote client (as the remote client
 // itself is not exported)
 func (r *SftpReader) CloseClient() {
-	r.client.Close()
+	if r.conn != nil {
+		r.conn.Close()
+	}
 }
 
 func (r *SftpReader) String() string {
 	return "SftpReader"
 }
 
-func (r *SftpReader) ensureInitialized(killChan chan error, ctx context.Context) {
+func (r *SftpReader) ensureInitialized() {
 	if r.initialized {
 		return
 	}
 
-	client, err := util.SftpClient(r.parameters.Server, r.parameters.Username, r.parameters.AuthMethods)
-	util.KillPipelineIfErr(err, killChan, ctx)
-
-	r.client = client
+	r.parameters.SftpOptions = r.SftpOptions
+	r.conn = util.NewSftpConnection(r.parameters)
 	r.initialized = true
 }
 
-func (r *SftpReader) walk(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
-	walker := r.client.Walk(r.parameters.Path)
-	for walker.Step() {
-		util.KillPipelineIfErr(walker.Err(), killChan, ctx)
-		if !walker.Stat().IsDir() {
-			r.sendObject(walker.Path(), outputChan, killChan, ctx)
+func (r *SftpReader) walk(outputChan chan data.JSON, ctx context.Context) error {
+	sent := make(map[string]bool)
+	return r.conn.Retry(func(client *sftp.Client) error {
+		r.client = client
+		walker := client.Walk(r.parameters.Path)
+		for walker.Step() {
+			if err := walker.Err(); err != nil {
+				return err
+			}
+			if walker.Stat().IsDir() || sent[walker.Path()] {
+				continue
+			}
+			if err := r.sendObject(walker.Path(), outputChan, ctx); err != nil {
+				return err
+			}
+			sent[walker.Path()] = true
 		}
-	}
+		return nil
+	})
 }
 
-func (r *SftpReader) sendObject(path string, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+func (r *SftpReader) sendObject(path string, outputChan chan data.JSON, ctx context.Context) error {
 	if r.FileNamesOnly {
-		r.sendFilePath(path, outputChan, killChan, ctx)
-	} else {
-		r.sendFile(path, outputChan, killChan, ctx)
+		return r.sendFilePath(path, outputChan, ctx)
 	}
+	return r.sendFile(path, outputChan, ctx)
 }
 
-func (r *SftpReader) sendFilePath(path string, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+func (r *SftpReader) sendFilePath(path string, outputChan chan data.JSON, ctx context.Context) error {
 	sftpPath := util.SftpPath{Path: path}
 	d, err := data.NewJSON(sftpPath)
-	util.KillPipelineIfErr(err, killChan, ctx)
-	outputChan <- d
+	if err != nil {
+		return err
+	}
+	select {
+	case outputChan <- d:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
 }
 
-func (r *SftpReader) sendFile(path string, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+func (r *SftpReader) sendFile(path string, outputChan chan data.JSON, ctx context.Context) error {
 	file, err := r.client.Open(path)
-
-	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		return err
+	}
 	defer file.Close()
 
-	r.IoReader.Reader = file
-	r.IoReader.ProcessData(nil, outputChan, killChan, ctx)
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	obj := &remoteObject{
+		path:           path,
+		size:           info.Size(),
+		version:        info.ModTime().UTC().Format(time.RFC3339Nano),
+		reader:         file,
+		serverChecksum: r.sidecarChecksum(path),
+	}
+	if !r.Walk {
+		obj.resumeFrom = r.ResumeFrom
+	}
+
+	if r.chunked() {
+		err = r.sendChunks(obj, outputChan, ctx)
+	} else {
+		err = r.readFile(obj, file, outputChan, ctx)
+	}
+	if err != nil {
+		return err
+	}
 
 	if r.DeleteObjects {
-		err = r.client.Remove(path)
-		util.KillPipelineIfErr(err, killChan, ctx)
+		return r.client.Remove(path)
+	}
+	return nil
+}
+
+// readFile sends the file using the embedded IoReader. Read errors are
+// returned, rather than halting the pipeline, so a dropped connection
+// can be re-established, in which case the payloads already sent are
+// skipped when the file is read again. If ctx is done before the whole
+// file is sent, it returns ctx.Err().
+func (r *SftpReader) readFile(obj *remoteObject, file io.Reader, outputChan chan data.JSON, ctx context.Context) error {
+	reader, h, err := r.hashReader(file)
+	if err != nil {
+		return err
+	}
+	if r.IoReader.Gzipped {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	if r.partial == nil {
+		r.partial = make(map[string]int)
+	}
+	skip := r.partial[obj.path]
+	errChan := make(chan error, 1)
+	ioReader := r.IoReader
+	ioReader.Reader = reader
+	ioReader.ForEachData(errChan, func(d data.JSON) {
+		if skip > 0 {
+			skip--
+			return
+		}
+		select {
+		case outputChan <- d:
+			r.partial[obj.path]++
+		case <-ctx.Done():
+		}
+	}, ctx)
+	select {
+	case err := <-errChan:
+		return err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	delete(r.partial, obj.path)
+	return r.complete(obj, h, 0, outputChan, ctx)
+}
+
+// sidecarChecksum returns a func reading the checksum published in a
+// "<path>.<algorithm>" file (e.g. "data.csv.md5") alongside the object.
+func (r *SftpReader) sidecarChecksum(path string) func(string) (string, error) {
+	return func(algorithm string) (string, error) {
+		f, err := r.client.Open(path + "." + algorithm)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		d, err := ioutil.ReadAll(f)
+		if err != nil {
+			return "", err
+		}
+		// Checksum files are usually in "<sum>  <filename>" format.
+		fields := strings.Fields(string(d))
+		if len(fields) == 0 {
+			return "", fmt.Errorf("SftpReader: empty checksum file %v.%v", path, algorithm)
+		}
+		return strings.ToLower(fields[0]), nil
 	}
 }
@@ -0,0 +1,59 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+)
+
+// failingReader returns err once r is exhausted, like a dropped connection.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestSftpReaderReadFileRetry(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	r := NewSftpReaderByClient(nil, "data.txt")
+	obj := &remoteObject{path: "data.txt"}
+	outputChan := make(chan data.JSON, 10)
+	dropped := errors.New("connection lost")
+
+	err := r.readFile(obj, &failingReader{strings.NewReader("a\nb\n"), dropped}, outputChan, context.Background())
+	if err != dropped {
+		t.Fatalf("got error %v, want %v", err, dropped)
+	}
+	// Read again after reconnecting, the lines already sent are skipped.
+	if err := r.readFile(obj, strings.NewReader("a\nb\nc\n"), outputChan, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(outputChan)
+	sent := []string{}
+	for d := range outputChan {
+		sent = append(sent, string(d))
+	}
+	if strings.Join(sent, ",") != "a,b,c" {
+		t.Errorf("sent %q, want each line once", sent)
+	}
+	if len(r.partial) != 0 {
+		t.Errorf("partial counts %v left after the file was sent", r.partial)
+	}
+}
+
+func TestSftpReaderCloseUnconnected(t *testing.T) {
+	r := NewSftpReader("localhost:22", "ratchet", "/data.txt")
+	r.Finish(make(chan data.JSON), make(chan error), context.Background())
+}
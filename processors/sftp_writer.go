@@ -11,13 +11,16 @@ import (
 	"github.com/rhansen2/ratchet/util"
 )
 
-// SftpWriter is an inline writer to remote sftp server
+// SftpWriter is an inline writer to remote sftp server.
+// The server's host key is verified, and the connection can be hardened
+// with timeouts and keepalives, using the embedded util.SftpOptions.
 type SftpWriter struct {
-	client        *sftp.Client
-	file          *sftp.File
-	parameters    *util.SftpParameters
-	initialized   bool
-	CloseOnFinish bool
+	util.SftpOptions // embeds SftpOptions
+	client           *sftp.Client
+	file             *sftp.File
+	parameters       *util.SftpParameters
+	initialized      bool
+	CloseOnFinish    bool
 }
 
 // NewSftpWriter instantiates a new sftp writer, a connection to the remote server is delayed until data is recv'd by the writer
@@ -47,7 +50,10 @@ func NewSftpWriterByFile(file *sftp.File) *SftpWriter {
 // ProcessData writes data as is directly to the output file
 func (w *SftpWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	logger.Debug("SftpWriter Process data:", string(d))
-	w.ensureInitialized(killChan, ctx)
+	if err := w.ensureInitialized(); err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
 	_, e := w.file.Write([]byte(d))
 	util.KillPipelineIfErr(e, killChan, ctx)
 }
@@ -55,8 +61,12 @@ func (w *SftpWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killCha
 // Finish optionally closes open references to the remote file and server
 func (w *SftpWriter) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	if w.CloseOnFinish {
-		w.file.Close()
-		w.client.Close()
+		if w.file != nil {
+			w.file.Close()
+		}
+		if w.client != nil {
+			w.client.Close()
+		}
 	}
 }
 
@@ -65,20 +75,27 @@ func (w *SftpWriter) String() string {
 }
 
 // ensureInitialized calls connect and then creates the output file on the sftp server at the specified path
-func (w *SftpWriter) ensureInitialized(killChan chan error, ctx context.Context) {
+func (w *SftpWriter) ensureInitialized() error {
 	if w.initialized {
-		return
+		return nil
 	}
 
-	client, err := util.SftpClient(w.parameters.Server, w.parameters.Username, w.parameters.AuthMethods)
-	util.KillPipelineIfErr(err, killChan, ctx)
+	w.parameters.SftpOptions = w.SftpOptions
+	client, err := util.SftpConnect(w.parameters)
+	if err != nil {
+		return err
+	}
 
 	logger.Info("Path", w.parameters.Path)
 
 	file, err := client.Create(w.parameters.Path)
-	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		client.Close()
+		return err
+	}
 
 	w.client = client
 	w.file = file
 	w.initialized = true
+	return nil
 }
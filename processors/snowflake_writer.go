@@ -0,0 +1,144 @@
+package processors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+// SnowflakeWriter bulk loads data.JSON into a Snowflake table. Data is staged
+// as gzip compressed CSV files (see WarehouseStaging) and loaded with COPY INTO,
+// which is much faster than INSERTing rows with a SQLWriter.
+//
+// By default, files are uploaded to an internal stage with PUT (which requires
+// a driver that supports PUT, such as gosnowflake). Use NewSnowflakeS3Writer to
+// upload files to S3 instead, for loading through an external stage.
+// Loaded files are removed from internal stages, but left in S3.
+//
+// Set Parquet to stage batches as Parquet files rather than CSV; their columns
+// are matched to the table's by name.
+//
+// Since identifiers are quoted, Snowflake matches them case-sensitively:
+// TableName and the keys of the received objects must match the case of an
+// existing table's names (e.g. ID for a column created without quotes), unless
+// the session sets QUOTED_IDENTIFIERS_IGNORE_CASE.
+type SnowflakeWriter struct {
+	WarehouseStaging // embeds WarehouseStaging
+	Parquet          bool
+	db               *sql.DB
+	stageName        string
+	upload           func(bucket, key string, body io.Reader) error
+	bucket           string
+	prefix           string
+}
+
+// NewSnowflakeWriter returns a new SnowflakeWriter loading tableName
+// through the given internal stage, e.g. "@~/ratchet" or "@%table".
+func NewSnowflakeWriter(db *sql.DB, tableName, stage string) *SnowflakeWriter {
+	return &SnowflakeWriter{WarehouseStaging: WarehouseStaging{TableName: tableName}, db: db, stageName: stage}
+}
+
+// NewSnowflakeS3Writer returns a new SnowflakeWriter loading tableName through
+// the given external stage, which must point to the prefix in the bucket.
+func NewSnowflakeS3Writer(db *sql.DB, tableName, stage, awsID, awsSecret, awsRegion, bucket, prefix string) *SnowflakeWriter {
+	w := NewSnowflakeWriter(db, tableName, stage)
+	creds := credentials.NewStaticCredentials(awsID, awsSecret, "")
+	w.upload = s3Uploader(aws.NewConfig().WithRegion(awsRegion).WithCredentials(creds))
+	w.bucket = bucket
+	w.prefix = prefix
+	return w
+}
+
+// ProcessData buffers the data, loading a batch once BatchSize rows are buffered.
+func (w *SnowflakeWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	if err := w.add(d); err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
+	if w.full() {
+		util.KillPipelineIfErr(w.load(ctx), killChan, ctx)
+	}
+}
+
+// Finish loads any remaining buffered data.
+func (w *SnowflakeWriter) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	util.KillPipelineIfErr(w.load(ctx), killChan, ctx)
+}
+
+func (w *SnowflakeWriter) String() string {
+	return "SnowflakeWriter"
+}
+
+var snowflakeTypes = warehouseTypes{
+	boolean: "BOOLEAN",
+	integer: "NUMBER(38,0)",
+	float:   "FLOAT",
+	text:    "VARCHAR",
+}
+
+func (w *SnowflakeWriter) load(ctx context.Context) error {
+	if err := w.createTable(ctx, w.db, snowflakeTypes); err != nil {
+		w.objects = nil
+		return err
+	}
+	stage := w.stage
+	if w.Parquet {
+		stage = w.stageParquet
+	}
+	batch, err := stage()
+	if err != nil || batch == nil {
+		return err
+	}
+
+	purge := "TRUE"
+	if w.bucket != "" {
+		key := path.Join(w.prefix, batch.name)
+		logger.Info("SnowflakeWriter: staging", batch.rows, "rows to", key)
+		if err := w.upload(w.bucket, key, batch.file); err != nil {
+			return err
+		}
+		purge = "FALSE"
+	} else if err := w.put(ctx, batch); err != nil {
+		return err
+	}
+
+	logger.Info("SnowflakeWriter: loading", batch.name, "into", w.TableName)
+	return warehouseExec(ctx, w.db, w.copySQL(batch, purge))
+}
+
+// copySQL returns the COPY INTO statement loading the staged batch.
+func (w *SnowflakeWriter) copySQL(batch *stagedBatch, purge string) string {
+	if w.Parquet {
+		return fmt.Sprintf(`COPY INTO %v FROM %v FILES = (%v) FILE_FORMAT = (TYPE = PARQUET) MATCH_BY_COLUMN_NAME = CASE_SENSITIVE PURGE = %v`,
+			quoteTableName(w.TableName), w.stageName, sqlString(batch.name), purge)
+	}
+	return fmt.Sprintf(`COPY INTO %v (%v) FROM %v FILES = (%v) FILE_FORMAT = (TYPE = CSV COMPRESSION = GZIP FIELD_OPTIONALLY_ENCLOSED_BY = '"' ESCAPE_UNENCLOSED_FIELD = NONE NULL_IF = ('\\N') EMPTY_FIELD_AS_NULL = FALSE) PURGE = %v`,
+		quoteTableName(w.TableName), quoteIdents(batch.columns), w.stageName, sqlString(batch.name), purge)
+}
+
+// put uploads the batch to the internal stage.
+func (w *SnowflakeWriter) put(ctx context.Context, batch *stagedBatch) error {
+	dir, err := ioutil.TempDir("", "ratchet-snowflake")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, batch.name)
+	if err := ioutil.WriteFile(file, batch.file.Bytes(), 0600); err != nil {
+		return err
+	}
+	logger.Info("SnowflakeWriter: staging", batch.rows, "rows to", w.stageName)
+	putSQL := fmt.Sprintf("PUT %v %v AUTO_COMPRESS = FALSE OVERWRITE = TRUE", sqlString("file://"+filepath.ToSlash(file)), w.stageName)
+	return warehouseExec(ctx, w.db, putSQL)
+}
@@ -48,11 +48,15 @@ func (s *SQLExecutor) ProcessData(d data.JSON, outputChan chan data.JSON, killCh
 	var err error
 	if s.query == "" && s.sqlGenerator != nil {
 		sql, err = s.sqlGenerator(d)
-		util.KillPipelineIfErr(err, killChan, ctx)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
 	} else if s.query != "" {
 		sql = s.query
 	} else {
-		killChan <- errors.New("SQLExecutor: must have either static query or sqlGenerator func")
+		util.KillPipelineIfErr(errors.New("SQLExecutor: must have either static query or sqlGenerator func"), killChan, ctx)
+		return
 	}
 
 	logger.Debug("SQLExecutor: Running - ", sql)
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 
+	"github.com/rhansen2/ratchet"
 	"github.com/rhansen2/ratchet/data"
 	"github.com/rhansen2/ratchet/logger"
 	"github.com/rhansen2/ratchet/util"
@@ -20,6 +21,10 @@ import (
 // The dynamic SQL generation is implemented by passing in a "sqlGenerator"
 // function to NewDynamicSQLReader. This allows you to write whatever code is
 // needed to generate SQL based upon data flowing through the pipeline.
+//
+// SQLReader is a ratchet.Source, so it's typically used in the first stage
+// of a Pipeline, where it runs its query once. In dynamic mode, the
+// sqlGenerator is then passed the ratchet.StartSignal.
 type SQLReader struct {
 	readDB            *sql.DB
 	query             string
@@ -43,6 +48,11 @@ func NewDynamicSQLReader(dbConn *sql.DB, sqlGenerator func(data.JSON) (string, e
 	return &SQLReader{readDB: dbConn, sqlGenerator: sqlGenerator, BatchSize: 1000}
 }
 
+// Start runs the query, as ProcessData does when sent the ratchet.StartSignal.
+func (s *SQLReader) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	s.ProcessData(data.JSON(ratchet.StartSignal), outputChan, killChan, ctx)
+}
+
 // ProcessData - see interface for documentation.
 func (s *SQLReader) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	s.ForEachQueryData(d, killChan, ctx, func(d data.JSON) {
@@ -61,22 +71,24 @@ func (s *SQLReader) ForEachQueryData(d data.JSON, killChan chan error, ctx conte
 	var err error
 	if s.query == "" && s.sqlGenerator != nil {
 		sql, err = s.sqlGenerator(d)
-		util.KillPipelineIfErr(err, killChan, ctx)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
 	} else if s.query != "" {
 		sql = s.query
 	} else {
-		select {
-		case <-ctx.Done():
-			return
-		case killChan <- errors.New("SQLReader: must have either static query or sqlGenerator func"):
-		}
+		util.KillPipelineIfErr(errors.New("SQLReader: must have either static query or sqlGenerator func"), killChan, ctx)
+		return
 	}
 
 	logger.Debug("SQLReader: Running - ", sql)
 	// See sql.go
 	dataChan, err := util.GetDataFromSQLQuery(s.readDB, sql, s.BatchSize, s.StructDestination, ctx)
-
-	util.KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		util.KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
 
 	for {
 		select {
@@ -91,9 +103,9 @@ func (s *SQLReader) ForEachQueryData(d data.JSON, killChan chan error, ctx conte
 			var derr dataErr
 			if err := data.ParseJSONSilent(d, &derr); err == nil {
 				util.KillPipelineIfErr(errors.New(derr.Error), killChan, ctx)
-			} else {
-				forEach(d)
+				return
 			}
+			forEach(d)
 		}
 	}
 
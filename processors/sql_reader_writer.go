@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/rhansen2/ratchet"
 	"github.com/rhansen2/ratchet/data"
 )
 
@@ -36,6 +37,11 @@ func NewDynamicSQLReaderWriter(readConn *sql.DB, writeConn *sql.DB, sqlGenerator
 	return s
 }
 
+// Start runs the query, as ProcessData does when sent the ratchet.StartSignal.
+func (s *SQLReaderWriter) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	s.ProcessData(data.JSON(ratchet.StartSignal), outputChan, killChan, ctx)
+}
+
 // ProcessData uses SQLReader methods for processing data - this works via composition
 func (s *SQLReaderWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	s.ForEachQueryData(d, killChan, ctx, func(d data.JSON) {
@@ -48,7 +54,7 @@ func (s *SQLReaderWriter) ProcessData(d data.JSON, outputChan chan data.JSON, ki
 }
 
 // Finish - see interface for documentation.
-func (s *SQLReaderWriter) Finish(outputChan chan data.JSON, killChan chan error) {
+func (s *SQLReaderWriter) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 }
 
 func (s *SQLReaderWriter) String() string {
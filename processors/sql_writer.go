@@ -58,7 +58,10 @@ func (s *SQLWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan
 	if err == nil && wd.TableName != "" && wd.InsertData != nil {
 		logger.Debug("SQLWriter: SQLWriterData scenario")
 		dd, err := data.NewJSON(wd.InsertData)
-		util.KillPipelineIfErr(err, killChan, ctx)
+		if err != nil {
+			util.KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
 		err = util.SQLInsertData(s.writeDB, dd, wd.TableName, s.OnDupKeyUpdate, s.OnDupKeyFields, s.BatchSize)
 		util.KillPipelineIfErr(err, killChan, ctx)
 	} else {
@@ -70,7 +73,7 @@ func (s *SQLWriter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan
 }
 
 // Finish - see interface for documentation.
-func (s *SQLWriter) Finish(outputChan chan data.JSON, killChan chan error) {
+func (s *SQLWriter) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 }
 
 func (s *SQLWriter) String() string {
@@ -0,0 +1,403 @@
+package processors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/util"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// WarehouseStaging configures how the warehouse writers that embed it
+// (RedshiftWriter and SnowflakeWriter) batch data for bulk loading.
+//
+// Received data must be a JSON object or a slice of objects, where the keys
+// are column names. Objects are buffered until BatchSize rows have been
+// received (and when the stage finishes), then written to a gzip compressed
+// CSV file (or a Parquet file, where the writer supports it) which is staged
+// and loaded into TableName with a single COPY. Only the columns present in a
+// batch are loaded, so other columns are left to their defaults.
+//
+// TableName and the column names are quoted in the generated SQL. TableName
+// may be qualified with a schema (e.g. "reports.events"), in which case each
+// part is quoted; if it contains a double quote, it's used as is.
+//
+// If CreateTable is set, the table is created (if it doesn't already exist)
+// before the first load, with column types inferred from the first batch.
+// Columns first seen in a later batch are added with ALTER TABLE, and a batch
+// whose values don't fit a created column's type (e.g. text in a BIGINT
+// column) is rejected. Nested objects and arrays are loaded as JSON text.
+//
+// A batch that fails to load is dropped after its error is sent.
+type WarehouseStaging struct {
+	TableName   string
+	BatchSize   int // Rows per staged file, default is 100000.
+	CreateTable bool
+	objects     []map[string]interface{}
+	runID       int64
+	batches     int
+	tableTypes  map[string]string // Types of the columns created with CreateTable.
+}
+
+// warehouseTypes maps the JSON types to a warehouse's column types.
+type warehouseTypes struct {
+	boolean, integer, float, text string
+}
+
+// stagedBatch is a batch of rows written as a gzip compressed CSV file.
+type stagedBatch struct {
+	name    string // A unique file name for the batch.
+	columns []string
+	rows    int
+	file    *bytes.Buffer
+}
+
+const warehouseNull = `\N`
+
+// add buffers the objects in d, keeping numbers in their original
+// text form so they're loaded without loss of precision.
+func (s *WarehouseStaging) add(d data.JSON) error {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		s.objects = append(s.objects, v)
+	case []interface{}:
+		for _, o := range v {
+			object, ok := o.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%v: expected a JSON object, got %v", s.TableName, o)
+			}
+			s.objects = append(s.objects, object)
+		}
+	default:
+		return fmt.Errorf("%v: expected a JSON object or slice of objects, got %v", s.TableName, v)
+	}
+	return nil
+}
+
+func (s *WarehouseStaging) full() bool {
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100000
+	}
+	return len(s.objects) >= batchSize
+}
+
+// newBatch returns a stagedBatch for the buffered objects, with the given
+// file extension, or nil if there are none.
+func (s *WarehouseStaging) newBatch(ext string) *stagedBatch {
+	if len(s.objects) == 0 {
+		return nil
+	}
+	if s.runID == 0 {
+		s.runID = time.Now().UnixNano()
+	}
+	s.batches++
+	return &stagedBatch{
+		name:    fmt.Sprintf("%v-%d-%d%v", s.TableName, s.runID, s.batches, ext),
+		columns: s.columns(),
+		rows:    len(s.objects),
+		file:    &bytes.Buffer{},
+	}
+}
+
+// stage writes the buffered objects to a new gzip compressed CSV stagedBatch
+// and clears them, returning nil if there are none. The objects are cleared
+// even if they can't be written, so the batch is dropped.
+func (s *WarehouseStaging) stage() (*stagedBatch, error) {
+	b := s.newBatch(".csv.gz")
+	if b == nil {
+		return nil, nil
+	}
+	defer s.clear()
+
+	gz := gzip.NewWriter(b.file)
+	w := util.NewCSVWriter()
+	w.SetWriter(gz)
+	w.AlwaysEncapsulate = false
+	w.QuoteEscape = `"`
+	for _, object := range s.objects {
+		row := make([]string, len(b.columns))
+		for i, col := range b.columns {
+			v, err := warehouseValue(object[col])
+			if err != nil {
+				return nil, err
+			}
+			row[i] = v
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *WarehouseStaging) clear() {
+	s.objects = nil
+}
+
+var parquetTypes = warehouseTypes{
+	boolean: "type=BOOLEAN",
+	integer: "type=INT64",
+	float:   "type=DOUBLE",
+	text:    "type=BYTE_ARRAY, convertedtype=UTF8",
+}
+
+// stageParquet writes the buffered objects to a new Parquet stagedBatch and
+// clears them (even if they can't be written), returning nil if there are
+// none. Every column is optional, and its type is inferred from the batch's
+// values as with CreateTable.
+func (s *WarehouseStaging) stageParquet() (*stagedBatch, error) {
+	b := s.newBatch(".parquet")
+	if b == nil {
+		return nil, nil
+	}
+	defer s.clear()
+	types := make([]string, len(b.columns))
+	for i, col := range b.columns {
+		if types[i] = s.columnType(col, parquetTypes); types[i] == "" {
+			types[i] = parquetTypes.text
+		}
+	}
+	schema, err := s.parquetSchema(b.columns, types)
+	if err != nil {
+		return nil, err
+	}
+
+	pw, err := writer.NewJSONWriterFromWriter(schema, b.file, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range s.objects {
+		row := make(map[string]interface{}, len(b.columns))
+		for i, col := range b.columns {
+			v := object[col]
+			if v != nil && types[i] == parquetTypes.text {
+				if v, err = warehouseValue(v); err != nil {
+					return nil, err
+				}
+			}
+			row[col] = v
+		}
+		d, err := data.NewJSON(row)
+		if err != nil {
+			return nil, err
+		}
+		if err := pw.Write(string(d)); err != nil {
+			return nil, err
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// parquetSchema returns the JSON schema for a Parquet file with
+// the columns, which have the given parquetTypes.
+func (s *WarehouseStaging) parquetSchema(columns, types []string) (string, error) {
+	type field struct {
+		Tag    string
+		Fields []field `json:",omitempty"`
+	}
+	root := field{Tag: "name=parquet_go_root, repetitiontype=REQUIRED"}
+	names := make(map[string]string)
+	for i, col := range columns {
+		// The schema is written as tags, and its fields are matched
+		// to keys by a name that isn't unique for every key.
+		if strings.ContainsAny(col, ",=\t") || strings.TrimSpace(col) != col || col == "" {
+			return "", fmt.Errorf("%v: column %q can't be written to Parquet", s.TableName, col)
+		}
+		name := common.StringToVariableName(col)
+		if other, ok := names[name]; ok {
+			return "", fmt.Errorf("%v: columns %q and %q can't both be written to Parquet", s.TableName, other, col)
+		}
+		names[name] = col
+		root.Fields = append(root.Fields, field{Tag: fmt.Sprintf("name=%v, %v, repetitiontype=OPTIONAL", col, types[i])})
+	}
+	schema, err := json.Marshal(root)
+	return string(schema), err
+}
+
+func (s *WarehouseStaging) columns() []string {
+	cols := make(map[string]struct{})
+	for _, o := range s.objects {
+		for col := range o {
+			cols[col] = struct{}{}
+		}
+	}
+	columns := make([]string, 0, len(cols))
+	for col := range cols {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// createTable creates the table (if CreateTable is set) for the buffered
+// objects, or adds the columns missing from the table created for earlier
+// batches. It fails if the objects' values don't fit a created column's type.
+func (s *WarehouseStaging) createTable(ctx context.Context, db *sql.DB, types warehouseTypes) error {
+	if !s.CreateTable || len(s.objects) == 0 {
+		return nil
+	}
+	table := quoteTableName(s.TableName)
+	if s.tableTypes == nil {
+		created := make(map[string]string)
+		defs := []string{}
+		for _, col := range s.columns() {
+			typ := s.columnType(col, types)
+			if typ == "" {
+				typ = types.text
+			}
+			created[col] = typ
+			defs = append(defs, fmt.Sprintf("%v %v", quoteIdent(col), typ))
+		}
+		stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (%v)", table, strings.Join(defs, ", "))
+		if err := warehouseExec(ctx, db, stmt); err != nil {
+			return err
+		}
+		s.tableTypes = created
+		return nil
+	}
+
+	for _, col := range s.columns() {
+		typ := s.columnType(col, types)
+		created, ok := s.tableTypes[col]
+		if ok {
+			if !types.fits(typ, created) {
+				return fmt.Errorf("%v: column %v was created as %v, but has %v values", s.TableName, col, created, typ)
+			}
+			continue
+		}
+		if typ == "" {
+			typ = types.text
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v %v", table, quoteIdent(col), typ)
+		if err := warehouseExec(ctx, db, stmt); err != nil {
+			return err
+		}
+		s.tableTypes[col] = typ
+	}
+	return nil
+}
+
+// columnType infers a column's type from its values, falling back to text
+// for mixed types. It returns "" if every value is null.
+func (s *WarehouseStaging) columnType(col string, types warehouseTypes) string {
+	typ := ""
+	for _, o := range s.objects {
+		var t string
+		switch v := o[col].(type) {
+		case nil:
+			continue
+		case bool:
+			t = types.boolean
+		case json.Number:
+			t = types.integer
+			if _, err := v.Int64(); err != nil {
+				t = types.float
+			}
+		default:
+			t = types.text
+		}
+		switch {
+		case typ == "" || typ == t:
+			typ = t
+		case (typ == types.integer && t == types.float) || (typ == types.float && t == types.integer):
+			typ = types.float
+		default:
+			return types.text
+		}
+	}
+	return typ
+}
+
+// warehouseExec executes the statement, returning ctx.Err() if it failed
+// because ctx is done (drivers report cancelled statements in their own way).
+func warehouseExec(ctx context.Context, db *sql.DB, stmt string) error {
+	_, err := db.ExecContext(ctx, stmt)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// fits reports whether values of type typ (as returned by columnType)
+// can be loaded into a column created as the given type.
+func (types warehouseTypes) fits(typ, column string) bool {
+	return typ == "" || typ == column || column == types.text || (typ == types.integer && column == types.float)
+}
+
+func warehouseValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return warehouseNull, nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	default:
+		d, err := data.NewJSON(v)
+		return string(d), err
+	}
+}
+
+// sqlString quotes s as a SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// quoteIdent quotes s as a SQL identifier.
+func quoteIdent(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// quoteIdents quotes each of the identifiers, joining them with commas.
+func quoteIdents(idents []string) string {
+	quoted := make([]string, len(idents))
+	for i, ident := range idents {
+		quoted[i] = quoteIdent(ident)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteTableName quotes each part of a table name that may be qualified with
+// a schema, e.g. reports.events. Names that are already quoted are used as is.
+func quoteTableName(name string) string {
+	if strings.Contains(name, `"`) {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quoteIdent(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,218 @@
+package processors
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+)
+
+// load sends each payload to dp followed by Finish, returning the errors sent.
+func load(ctx context.Context, dp interface {
+	ProcessData(data.JSON, chan data.JSON, chan error, context.Context)
+	Finish(chan data.JSON, chan error, context.Context)
+}, payloads ...string) []error {
+	killChan := make(chan error, len(payloads)+1)
+	for _, d := range payloads {
+		dp.ProcessData(data.JSON(d), nil, killChan, ctx)
+	}
+	dp.Finish(nil, killChan, ctx)
+	close(killChan)
+	var errs []error
+	for err := range killChan {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func newMock(t *testing.T) (sqlmock.Sqlmock, func() *SnowflakeWriter) {
+	logger.LogLevel = logger.LevelSilent
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return mock, func() *SnowflakeWriter { return NewSnowflakeWriter(db, "reports.events", "@~/ratchet") }
+}
+
+func TestSnowflakeWriterLoadsBatches(t *testing.T) {
+	mock, newWriter := newMock(t)
+	w := newWriter()
+	w.CreateTable = true
+	w.BatchSize = 2
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "reports"."events" ("a" NUMBER(38,0), "b""c" VARCHAR)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`PUT 'file://.*/reports\.events-\d+-1\.csv\.gz' @~/ratchet AUTO_COMPRESS = FALSE OVERWRITE = TRUE`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`COPY INTO "reports"."events" ("a", "b""c") FROM @~/ratchet FILES = ('reports.events-`) + `\d+-1\.csv\.gz'`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "reports"."events" ADD COLUMN "d" BOOLEAN`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`PUT .*-2\.csv\.gz`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`COPY INTO "reports"."events" ("a", "d") FROM`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	errs := load(context.Background(), w, `{"a":1,"b\"c":"x"}`, `[{"a":2,"b\"c":null}]`, `{"a":null,"d":true}`)
+	if len(errs) != 0 {
+		t.Errorf("got errors %v", errs)
+	}
+}
+
+func TestSnowflakeWriterRejectsMismatchedTypes(t *testing.T) {
+	mock, newWriter := newMock(t)
+	w := newWriter()
+	w.CreateTable = true
+	w.BatchSize = 1
+
+	mock.ExpectExec(`CREATE TABLE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`PUT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`COPY INTO`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	errs := load(context.Background(), w, `{"a":1}`, `{"a":"x"}`)
+	want := "reports.events: column a was created as NUMBER(38,0), but has VARCHAR values"
+	if len(errs) != 1 || errs[0].Error() != want {
+		t.Errorf("got errors %v, want %q", errs, want)
+	}
+}
+
+func TestSnowflakeWriterCancelled(t *testing.T) {
+	mock, newWriter := newMock(t)
+	w := newWriter()
+	mock.ExpectExec(`PUT`).WillDelayFor(time.Minute).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	done := make(chan []error)
+	go func() { done <- load(ctx, w, `{"a":1}`) }()
+	select {
+	case errs := <-done:
+		if len(errs) != 0 {
+			t.Errorf("got errors %v", errs)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("load wasn't cancelled")
+	}
+}
+
+func TestSnowflakeWriterParquet(t *testing.T) {
+	mock, newWriter := newMock(t)
+	w := newWriter()
+	w.Parquet = true
+	mock.ExpectExec(`PUT 'file://.*\.parquet' @~/ratchet`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`COPY INTO "reports"."events" FROM @~/ratchet FILES = ('reports.events-`) +
+		`\d+-1\.parquet'\) FILE_FORMAT = \(TYPE = PARQUET\) MATCH_BY_COLUMN_NAME = CASE_SENSITIVE PURGE = TRUE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if errs := load(context.Background(), w, `{"a":1,"b":{"c":true}}`); len(errs) != 0 {
+		t.Errorf("got errors %v", errs)
+	}
+}
+
+// TestSnowflakeWriterDropsBadBatch checks a batch that can't be staged is
+// dropped, rather than failing every later batch.
+func TestSnowflakeWriterDropsBadBatch(t *testing.T) {
+	mock, newWriter := newMock(t)
+	w := newWriter()
+	w.Parquet = true
+	w.BatchSize = 1
+	mock.ExpectExec(`PUT .*-2\.parquet`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`COPY INTO .*-2\.parquet`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	errs := load(context.Background(), w, `{"a,b":1}`, `{"a":2}`)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `column "a,b" can't be written to Parquet`) {
+		t.Errorf("got errors %v, want the first batch's", errs)
+	}
+	if len(w.objects) != 0 {
+		t.Errorf("%d objects still buffered", len(w.objects))
+	}
+}
+
+func TestParquetSchema(t *testing.T) {
+	s := &WarehouseStaging{TableName: "events"}
+	if err := s.add(data.JSON(`[{"a":1,"b":1.5,"c":true,"d":{"e":1}},{"a":2,"b":2,"c":null,"d":null,"f":null}]`)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.stageParquet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.rows != 2 || strings.Join(b.columns, ",") != "a,b,c,d,f" {
+		t.Errorf("got batch with %d rows and columns %v", b.rows, b.columns)
+	}
+
+	types := []string{parquetTypes.integer, parquetTypes.float, parquetTypes.boolean, parquetTypes.text, parquetTypes.text}
+	schema, err := s.parquetSchema(b.columns, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root struct {
+		Fields []struct{ Tag string }
+	}
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"name=a, type=INT64, repetitiontype=OPTIONAL",
+		"name=b, type=DOUBLE, repetitiontype=OPTIONAL",
+		"name=c, type=BOOLEAN, repetitiontype=OPTIONAL",
+		"name=d, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL",
+		"name=f, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL",
+	}
+	for i, f := range root.Fields {
+		if i >= len(want) || f.Tag != want[i] {
+			t.Errorf("got fields %+v, want %q", root.Fields, want)
+			break
+		}
+	}
+
+	for _, columns := range [][]string{{"a,b"}, {"a=b"}, {" a"}, {"a", "A"}} {
+		if _, err := s.parquetSchema(columns, types[:len(columns)]); err == nil {
+			t.Errorf("columns %q: expected an error", columns)
+		}
+	}
+}
+
+func TestRedshiftWriterCopy(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	w := NewRedshiftWriter(db, "events", "id", "it's secret", "us-east-1", "bucket")
+	w.Prefix = "staging"
+	var uploaded []string
+	w.upload = func(bucket, key string, body io.Reader) error {
+		ioutil.ReadAll(body)
+		uploaded = append(uploaded, bucket+"/"+key)
+		return nil
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`COPY "events" ("a", "select") FROM 's3://bucket/staging/events-`) +
+		`\d+-1\.csv\.gz' ` + regexp.QuoteMeta(`ACCESS_KEY_ID 'id' SECRET_ACCESS_KEY 'it''s secret' REGION 'us-east-1' FORMAT AS CSV GZIP NULL AS '\\N'`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if errs := load(context.Background(), w, `{"a":1,"select":"x"}`); len(errs) != 0 {
+		t.Errorf("got errors %v", errs)
+	}
+	if len(uploaded) != 1 || !strings.HasPrefix(uploaded[0], "bucket/staging/events-") {
+		t.Errorf("uploaded %v", uploaded)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,8 @@
+// Package ratchettest provides utilities for testing DataProcessors, without
+// needing to set up the channels and killChan handling a Pipeline provides.
+//
+// Process runs a single DataProcessor against in-memory input payloads,
+// recording what it sends and any errors, while Feed, Capture, and
+// RunPipeline test a small layout end to end. AssertGolden compares output
+// (such as that written by a CSVWriter) with a golden file under testdata.
+package ratchettest
@@ -0,0 +1,51 @@
+package ratchettest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update is set with "go test -ratchettest.update" to
+// (re)write golden files rather than comparing with them.
+var update = flag.Bool("ratchettest.update", false, "update ratchettest golden files")
+
+// AssertGolden checks that got matches the contents of the golden file
+// testdata/<name>. Run the tests with -ratchettest.update to write
+// got to the golden file instead, e.g. when the output is first added
+// or is expected to change.
+func AssertGolden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -ratchettest.update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output doesn't match %v (run with -ratchettest.update to update it):\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// AssertGoldenFile checks that the file at path (written by a DataProcessor
+// such as a CSVWriter) matches the golden file testdata/<name>, see AssertGolden.
+func AssertGoldenFile(t testing.TB, name string, path string) {
+	t.Helper()
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertGolden(t, name, got)
+}
@@ -0,0 +1,299 @@
+package ratchettest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/data"
+)
+
+// Timeout is how long a DataProcessor or Pipeline is given before
+// the test fails, to catch processors that block forever.
+var Timeout = 10 * time.Second
+
+// Result records what a DataProcessor did when run by Process.
+type Result struct {
+	Outputs       []data.JSON // Sent by ProcessData (or Start, for a ratchet.Source).
+	FinishOutputs []data.JSON // Sent by Finish.
+	Errors        []error     // Sent on the killChan, from any call.
+	Finished      bool        // Whether Finish returned.
+}
+
+// Process calls ProcessData on p with each of the inputs in turn, and then
+// calls Finish, recording what's sent. With no inputs, p is started as it
+// would be in the first stage of a Pipeline: a ratchet.Source has Start
+// called, and other DataProcessors are sent the ratchet.StartSignal.
+//
+// Unlike a Pipeline, an error sent on the killChan doesn't stop processing,
+// so every error is recorded. The test fails if any call doesn't return
+// within Timeout.
+func Process(t testing.TB, p ratchet.DataProcessor, inputs ...data.JSON) *Result {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &Result{}
+	run := func(name string, f func(outputChan chan data.JSON, killChan chan error)) []data.JSON {
+		t.Helper()
+		outputs, errs, err := call(ctx, f)
+		r.Errors = append(r.Errors, errs...)
+		if err != nil {
+			t.Fatalf("%v %v: %v", p, name, err)
+		}
+		return outputs
+	}
+
+	if source, ok := p.(ratchet.Source); ok && len(inputs) == 0 {
+		r.Outputs = run("Start", func(outputChan chan data.JSON, killChan chan error) {
+			source.Start(outputChan, killChan, ctx)
+		})
+	} else {
+		if len(inputs) == 0 {
+			inputs = []data.JSON{data.JSON(ratchet.StartSignal)}
+		}
+		for _, d := range inputs {
+			outputs := run("ProcessData", func(outputChan chan data.JSON, killChan chan error) {
+				p.ProcessData(d, outputChan, killChan, ctx)
+			})
+			r.Outputs = append(r.Outputs, outputs...)
+		}
+	}
+	r.FinishOutputs = run("Finish", func(outputChan chan data.JSON, killChan chan error) {
+		p.Finish(outputChan, killChan, ctx)
+	})
+	r.Finished = true
+	return r
+}
+
+// call runs f, collecting what it sends until it returns.
+func call(ctx context.Context, f func(outputChan chan data.JSON, killChan chan error)) ([]data.JSON, []error, error) {
+	outputChan := make(chan data.JSON)
+	killChan := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(outputChan, killChan)
+	}()
+
+	var outputs []data.JSON
+	var errs []error
+	timeout := time.After(Timeout)
+	for {
+		select {
+		case d := <-outputChan:
+			outputs = append(outputs, d)
+		case err := <-killChan:
+			errs = append(errs, err)
+		case <-done:
+			return outputs, errs, nil
+		case <-timeout:
+			return outputs, errs, fmt.Errorf("didn't return within %v", Timeout)
+		}
+	}
+}
+
+// AssertOutputs checks that the payloads sent by ProcessData are
+// equivalent JSON to want (see AssertJSON).
+func (r *Result) AssertOutputs(t testing.TB, want ...string) {
+	t.Helper()
+	assertPayloads(t, "outputs", r.Outputs, want)
+}
+
+// AssertFinishOutputs checks that the payloads sent by Finish are
+// equivalent JSON to want (see AssertJSON).
+func (r *Result) AssertFinishOutputs(t testing.TB, want ...string) {
+	t.Helper()
+	assertPayloads(t, "Finish outputs", r.FinishOutputs, want)
+}
+
+// AssertNoErrors checks that nothing was sent on the killChan.
+func (r *Result) AssertNoErrors(t testing.TB) {
+	t.Helper()
+	for _, err := range r.Errors {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// AssertError checks that an error containing substr was sent on the killChan.
+func (r *Result) AssertError(t testing.TB, substr string) {
+	t.Helper()
+	for _, err := range r.Errors {
+		if strings.Contains(err.Error(), substr) {
+			return
+		}
+	}
+	t.Errorf("no error containing %q, got %v", substr, r.Errors)
+}
+
+func assertPayloads(t testing.TB, name string, got []data.JSON, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("got %d %v, want %d:\n%v", len(got), name, len(want), payloadList(got))
+		return
+	}
+	for i := range got {
+		if !JSONEqual(got[i], want[i]) {
+			t.Errorf("%v[%d] = %s, want %s", name, i, got[i], want[i])
+		}
+	}
+}
+
+func payloadList(payloads []data.JSON) string {
+	lines := make([]string, len(payloads))
+	for i, d := range payloads {
+		lines[i] = fmt.Sprintf("  %d: %s", i, d)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AssertJSON checks that got is equivalent JSON to want, ignoring
+// formatting and the order of object keys.
+func AssertJSON(t testing.TB, got data.JSON, want string) {
+	t.Helper()
+	if !JSONEqual(got, want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// JSONEqual reports whether got is equivalent JSON to want. Payloads that
+// aren't valid JSON (such as lines sent by IoReader) are compared as text.
+func JSONEqual(got data.JSON, want string) bool {
+	var g, w interface{}
+	if json.Unmarshal(got, &g) != nil || json.Unmarshal([]byte(want), &w) != nil {
+		return string(got) == want
+	}
+	return reflect.DeepEqual(g, w)
+}
+
+// Feeder is a ratchet.Source that sends the given payloads, for
+// use as the first stage of a Pipeline under test.
+type Feeder struct {
+	payloads []data.JSON
+}
+
+// Feed returns a new Feeder sending the payloads.
+func Feed(payloads ...data.JSON) *Feeder {
+	return &Feeder{payloads: payloads}
+}
+
+// FeedJSON returns a new Feeder sending the payloads given as strings.
+func FeedJSON(payloads ...string) *Feeder {
+	f := &Feeder{}
+	for _, d := range payloads {
+		f.payloads = append(f.payloads, data.JSON(d))
+	}
+	return f
+}
+
+// Start sends each payload.
+func (f *Feeder) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	for _, d := range f.payloads {
+		select {
+		case outputChan <- d:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ProcessData sends each payload, see Start.
+func (f *Feeder) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	f.Start(outputChan, killChan, ctx)
+}
+
+// Finish - see interface for documentation.
+func (f *Feeder) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (f *Feeder) String() string {
+	return "Feeder"
+}
+
+// Capture records every payload it receives, for use as
+// the final stage of a Pipeline under test.
+type Capture struct {
+	payloads []data.JSON
+	finished bool
+	sync.Mutex
+}
+
+// NewCapture returns a new Capture.
+func NewCapture() *Capture {
+	return &Capture{}
+}
+
+// ProcessData records d.
+func (c *Capture) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	c.Lock()
+	c.payloads = append(c.payloads, d)
+	c.Unlock()
+}
+
+// Finish records that the Capture was finished.
+func (c *Capture) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	c.Lock()
+	c.finished = true
+	c.Unlock()
+}
+
+// Payloads returns the payloads received so far.
+func (c *Capture) Payloads() []data.JSON {
+	c.Lock()
+	defer c.Unlock()
+	return append([]data.JSON(nil), c.payloads...)
+}
+
+// Finished reports whether Finish has been called.
+func (c *Capture) Finished() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.finished
+}
+
+// AssertPayloads checks that the payloads received are
+// equivalent JSON to want (see AssertJSON).
+func (c *Capture) AssertPayloads(t testing.TB, want ...string) {
+	t.Helper()
+	assertPayloads(t, "payloads", c.Payloads(), want)
+}
+
+func (c *Capture) String() string {
+	return "Capture"
+}
+
+// RunPipeline runs p and returns the error (if any) it completes with.
+// The test fails if p doesn't complete within Timeout.
+func RunPipeline(t testing.TB, p *ratchet.Pipeline) error {
+	t.Helper()
+	select {
+	case err := <-p.Run():
+		return err
+	case <-time.After(Timeout):
+		t.Fatalf("%v didn't complete within %v", p.Name, Timeout)
+		return nil
+	}
+}
+
+// Chain runs the processors as a Pipeline, fed with the inputs, and
+// returns a Result with what the final processor sent and the error
+// (if any) the Pipeline completed with.
+func Chain(t testing.TB, inputs []data.JSON, processors ...ratchet.DataProcessor) *Result {
+	t.Helper()
+	capture := NewCapture()
+	dps := append([]ratchet.DataProcessor{Feed(inputs...)}, processors...)
+	p := ratchet.NewPipeline(context.Background(), nil, append(dps, capture)...)
+	r := &Result{}
+	if err := RunPipeline(t, p); err != nil {
+		r.Errors = append(r.Errors, err)
+	}
+	r.Outputs = capture.Payloads()
+	r.Finished = capture.Finished()
+	return r
+}
@@ -0,0 +1,36 @@
+package ratchettest_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/processors"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+func TestProcess(t *testing.T) {
+	matcher := processors.NewRegexpMatcher("ERROR")
+	r := ratchettest.Process(t, matcher, data.JSON(`"ERROR: disk full"`), data.JSON(`"INFO: ok"`))
+
+	r.AssertNoErrors(t)
+	r.AssertOutputs(t, `"ERROR: disk full"`)
+	r.AssertFinishOutputs(t)
+}
+
+func TestChain(t *testing.T) {
+	inputs := []data.JSON{data.JSON(`"ERROR: disk full"`), data.JSON(`"INFO: ok"`)}
+	r := ratchettest.Chain(t, inputs, processors.NewRegexpMatcher("INFO"), processors.NewPassthrough())
+
+	r.AssertNoErrors(t)
+	r.AssertOutputs(t, `"INFO: ok"`)
+}
+
+func TestAssertGolden(t *testing.T) {
+	var buf bytes.Buffer
+	writer := processors.NewCSVWriter(&buf)
+	r := ratchettest.Process(t, writer, data.JSON(`[{"a":1,"b":"x"},{"a":2,"b":"y"}]`))
+
+	r.AssertNoErrors(t)
+	ratchettest.AssertGolden(t, "csv_writer.golden", buf.Bytes())
+}
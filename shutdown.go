@@ -0,0 +1,163 @@
+package ratchet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/rhansen2/ratchet/logger"
+)
+
+// DefaultShutdownGracePeriod is how long a Pipeline being shut down waits
+// for its stages to finish if ShutdownGracePeriod isn't set.
+var DefaultShutdownGracePeriod = 30 * time.Second
+
+var (
+	// ErrShutdown is sent on Run's killChan when the Pipeline was shut down
+	// (see Pipeline.Shutdown) and every stage finished within the grace period.
+	ErrShutdown = errors.New("ratchet: pipeline shut down")
+	// ErrShutdownTimeout is sent on Run's killChan when the Pipeline was shut
+	// down, but its stages didn't finish within the grace period and were cancelled.
+	ErrShutdownTimeout = errors.New("ratchet: pipeline shut down, but didn't finish within the grace period")
+)
+
+// shutdown tracks a Pipeline's contexts and graceful shutdown.
+type shutdown struct {
+	init        sync.Once
+	cancel      context.CancelFunc
+	sourceCtx   context.Context
+	stopSources context.CancelFunc
+	done        chan struct{}
+	requested   bool
+	timedOut    bool
+	timer       *time.Timer
+	signals     []os.Signal
+	sync.Mutex
+}
+
+// initShutdown sets up the contexts used to shut down the Pipeline. The
+// first stage's DataProcessors are run with sourceCtx, which is cancelled
+// when shutting down, while the later stages keep running with p.ctx until
+// they've finished or the grace period is over.
+func (p *Pipeline) initShutdown() {
+	p.shutdown.init.Do(func() {
+		p.ctx, p.shutdown.cancel = context.WithCancel(p.ctx)
+		p.shutdown.sourceCtx, p.shutdown.stopSources = context.WithCancel(p.ctx)
+		p.shutdown.done = make(chan struct{})
+	})
+}
+
+// Shutdown gracefully stops the Pipeline. The first stage's DataProcessors
+// are stopped, by cancelling the context passed to their ProcessData (or
+// Start, for a Source) calls, and the remaining stages are then given
+// ShutdownGracePeriod to process the data already sent and call Finish
+// (e.g. to finish writing files). If they haven't finished by then, the
+// Pipeline is cancelled.
+//
+// Run's killChan receives ErrShutdown if the Pipeline finished within the
+// grace period, or ErrShutdownTimeout if it was cancelled. With CollectErrors,
+// a Pipeline that collected errors before finishing sends its *PipelineErrors
+// instead, with Shutdown set. Note that DataProcessors which don't check
+// their context can't be stopped early.
+//
+// Shutdown does nothing once the Pipeline has completed.
+func (p *Pipeline) Shutdown() {
+	p.initShutdown()
+	s := &p.shutdown
+	s.Lock()
+	defer s.Unlock()
+	if s.requested || s.completed() {
+		return
+	}
+	s.requested = true
+	grace := p.ShutdownGracePeriod
+	if grace <= 0 {
+		grace = DefaultShutdownGracePeriod
+	}
+	logger.Status(p.Name, ": shutting down, waiting up to", grace, "for stages to finish")
+	s.stopSources()
+	s.timer = time.AfterFunc(grace, func() {
+		s.Lock()
+		s.timedOut = true
+		s.Unlock()
+		logger.Error(p.Name, ": stages didn't finish within", grace, "- cancelling")
+		s.cancel()
+	})
+}
+
+// HandleSignals calls Shutdown when one of the given signals is received
+// (typically os.Interrupt and syscall.SIGTERM), from when Run is called until
+// the Pipeline completes. A second signal cancels the Pipeline immediately,
+// without waiting for the grace period. It must be called before Run.
+func (p *Pipeline) HandleSignals(signals ...os.Signal) {
+	p.shutdown.Lock()
+	defer p.shutdown.Unlock()
+	p.shutdown.signals = append(p.shutdown.signals, signals...)
+}
+
+// handleSignals starts handling the signals passed to HandleSignals, if any.
+func (p *Pipeline) handleSignals() {
+	s := &p.shutdown
+	s.Lock()
+	signals := s.signals
+	s.Unlock()
+	if len(signals) == 0 {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case sig := <-c:
+				s.Lock()
+				requested := s.requested
+				s.Unlock()
+				if requested {
+					logger.Status(p.Name, ": received", sig, "again, cancelling")
+					s.cancel()
+					return
+				}
+				logger.Status(p.Name, ": received", sig)
+				p.Shutdown()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// complete marks the Pipeline as done and cancels its context (releasing
+// it, and stopping any stages still running after an error), returning
+// ErrShutdown or ErrShutdownTimeout if it was shut down, or nil otherwise.
+func (p *Pipeline) complete() error {
+	s := &p.shutdown
+	s.Lock()
+	defer s.Unlock()
+	if !s.completed() {
+		close(s.done)
+		s.cancel()
+	}
+	switch {
+	case s.timedOut:
+		return ErrShutdownTimeout
+	case s.requested:
+		s.timer.Stop()
+		return ErrShutdown
+	}
+	return nil
+}
+
+// completed reports whether the Pipeline has completed. s must be locked.
+func (s *shutdown) completed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
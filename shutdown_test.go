@@ -0,0 +1,274 @@
+package ratchet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+)
+
+// endlessSource sends payloads until its context is done.
+type endlessSource struct {
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func newEndlessSource() *endlessSource {
+	return &endlessSource{started: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func (s *endlessSource) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	defer close(s.stopped)
+	for i := 0; ; i++ {
+		select {
+		case outputChan <- data.JSON(`{"a":1}`):
+		case <-ctx.Done():
+			return
+		}
+		if i == 10 {
+			close(s.started)
+		}
+	}
+}
+
+func (s *endlessSource) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (s *endlessSource) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+}
+
+func (s *endlessSource) String() string {
+	return "endlessSource"
+}
+
+// slowFinish passes payloads on, or fails them if fail is set, and takes
+// delay to finish, sending a final payload unless its context is done first.
+type slowFinish struct {
+	delay     time.Duration
+	fail      bool
+	finished  bool
+	cancelled bool
+}
+
+func (f *slowFinish) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	if f.fail {
+		select {
+		case killChan <- errors.New("failed"):
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case outputChan <- d:
+	case <-ctx.Done():
+	}
+}
+
+func (f *slowFinish) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	select {
+	case <-time.After(f.delay):
+		outputChan <- data.JSON(`{"finished":true}`)
+		f.finished = true
+	case <-ctx.Done():
+		f.cancelled = true
+	}
+}
+
+func (f *slowFinish) String() string {
+	return "slowFinish"
+}
+
+// sink records the last payload it receives, and whether Finish and Commit
+// were called.
+type sink struct {
+	last      string
+	finished  bool
+	committed bool
+	sync.Mutex
+}
+
+func (s *sink) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	s.Lock()
+	defer s.Unlock()
+	s.last = string(d)
+}
+
+func (s *sink) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	s.finished = true
+}
+
+func (s *sink) Commit() error {
+	s.committed = true
+	return nil
+}
+
+func (s *sink) String() string {
+	return "sink"
+}
+
+// runUntil runs p, calling stop once source has started, and returns the
+// error p completes with. It then waits for every stage's goroutine, which
+// can outlive Run once the Pipeline is cancelled, so the test can check
+// their state (and they don't race with the next test).
+func runUntil(t *testing.T, p *Pipeline, source *endlessSource, stop func()) error {
+	t.Helper()
+	killChan := p.Run()
+	<-source.started
+	stop()
+	var err error
+	select {
+	case err = <-killChan:
+	case <-time.After(10 * time.Second):
+		t.Fatal("pipeline didn't complete")
+	}
+	p.wg.Wait()
+	return err
+}
+
+func TestShutdownWithinGracePeriod(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	source := newEndlessSource()
+	finish := &slowFinish{delay: 50 * time.Millisecond}
+	last := &sink{}
+	p := NewPipeline(context.Background(), nil, source, finish, last)
+	p.ShutdownGracePeriod = 5 * time.Second
+
+	if err := runUntil(t, p, source, p.Shutdown); err != ErrShutdown {
+		t.Errorf("got error %v, want ErrShutdown", err)
+	}
+	// Only the source's context is cancelled, the later stages finish normally.
+	select {
+	case <-source.stopped:
+	default:
+		t.Error("source wasn't stopped")
+	}
+	if finish.cancelled || !finish.finished || !last.finished || last.last != `{"finished":true}` {
+		t.Errorf("stages didn't finish (cancelled: %v, last payload: %s)", finish.cancelled, last.last)
+	}
+	if !last.committed {
+		t.Error("Commit wasn't called after shutting down")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	source := newEndlessSource()
+	finish := &slowFinish{delay: time.Minute}
+	last := &sink{}
+	p := NewPipeline(context.Background(), nil, source, finish, last)
+	p.ShutdownGracePeriod = 50 * time.Millisecond
+
+	var began time.Time
+	err := runUntil(t, p, source, func() {
+		began = time.Now()
+		p.Shutdown()
+	})
+	if err != ErrShutdownTimeout {
+		t.Errorf("got error %v, want ErrShutdownTimeout", err)
+	}
+	if took := time.Since(began); took < p.ShutdownGracePeriod {
+		t.Errorf("cancelled after %v, before the grace period of %v", took, p.ShutdownGracePeriod)
+	}
+	if !finish.cancelled {
+		t.Error("slow Finish wasn't cancelled")
+	}
+	if last.committed {
+		t.Error("Commit was called on a cancelled Pipeline")
+	}
+}
+
+func TestShutdownSecondSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("can't send os.Interrupt to this process on Windows")
+	}
+	logger.LogLevel = logger.LevelSilent
+	source := newEndlessSource()
+	finish := &slowFinish{delay: time.Minute}
+	p := NewPipeline(context.Background(), nil, source, finish, &sink{})
+	p.ShutdownGracePeriod = time.Minute
+	p.HandleSignals(os.Interrupt)
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runUntil(t, p, source, func() {
+		self.Signal(os.Interrupt)
+		<-source.stopped
+		self.Signal(os.Interrupt)
+	})
+	if err != context.Canceled {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	if !finish.cancelled {
+		t.Error("slow Finish wasn't cancelled")
+	}
+}
+
+func TestHandleSignalsWithoutRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("can't send os.Interrupt to this process on Windows")
+	}
+	p := NewPipeline(context.Background(), nil, newEndlessSource())
+	p.HandleSignals(os.Interrupt)
+
+	// Until Run is called, the signal is only delivered to c.
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	defer signal.Stop(c)
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	self.Signal(os.Interrupt)
+	<-c
+	time.Sleep(10 * time.Millisecond)
+	p.shutdown.Lock()
+	defer p.shutdown.Unlock()
+	if p.shutdown.requested {
+		t.Error("a signal shut down a Pipeline that wasn't run")
+	}
+}
+
+func TestShutdownKeepsCollectedErrors(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	source := newEndlessSource()
+	p := NewPipeline(context.Background(), nil, source, &slowFinish{fail: true}, &sink{})
+	p.ErrorPolicy = CollectErrors
+
+	err := runUntil(t, p, source, p.Shutdown)
+	errs, ok := err.(*PipelineErrors)
+	if !ok {
+		t.Fatalf("got error %v, want *PipelineErrors", err)
+	}
+	if !errs.Shutdown || errs.Total == 0 || errs.Counts["2.1 slowFinish"] != errs.Total {
+		t.Errorf("got errors %v (shutdown: %v), want the errors collected before shutting down", errs.Counts, errs.Shutdown)
+	}
+}
+
+func TestShutdownAfterCompletion(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	p := NewPipeline(context.Background(), nil, &sink{})
+	select {
+	case err := <-p.Run():
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("pipeline didn't complete")
+	}
+	p.Shutdown()
+	if p.shutdown.requested || p.shutdown.timer != nil {
+		t.Error("Shutdown after completion started shutting down")
+	}
+	if p.ctx.Err() == nil {
+		t.Error("the Pipeline's context wasn't released on completion")
+	}
+}
@@ -0,0 +1,91 @@
+package ratchet_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/rhansen2/ratchet"
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/ratchettest"
+)
+
+// finishCounter records how Finish is called relative to ProcessData.
+type finishCounter struct {
+	concurrency int
+	mu          sync.Mutex
+	processing  int
+	processed   int
+	finishes    int
+	overlapped  bool // Finish was called while ProcessData was running.
+	late        bool // ProcessData was called after Finish.
+}
+
+func (c *finishCounter) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	c.mu.Lock()
+	c.late = c.late || c.finishes > 0
+	c.processing++
+	c.mu.Unlock()
+	outputChan <- d
+	c.mu.Lock()
+	c.processing--
+	c.processed++
+	c.mu.Unlock()
+}
+
+func (c *finishCounter) Finish(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finishes++
+	c.overlapped = c.overlapped || c.processing > 0
+}
+
+func (c *finishCounter) Concurrency() int {
+	return c.concurrency
+}
+
+func (c *finishCounter) String() string {
+	return "finishCounter"
+}
+
+func (c *finishCounter) check(t *testing.T, processed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finishes != 1 || c.overlapped || c.late || c.processed != processed {
+		t.Errorf("Finish called %d times (overlapped: %v, late: %v) after processing %d payloads, want once after %d",
+			c.finishes, c.overlapped, c.late, c.processed, processed)
+	}
+}
+
+// sourceCounter is a Source sending n payloads, with a configured concurrency
+// that should be ignored.
+type sourceCounter struct {
+	finishCounter
+	n int
+}
+
+func (s *sourceCounter) Start(outputChan chan data.JSON, killChan chan error, ctx context.Context) {
+	for i := 0; i < s.n; i++ {
+		s.ProcessData(data.JSON(`{"a":1}`), outputChan, killChan, ctx)
+	}
+}
+
+// TestFinishCalledOnce is meant to be run with -race. Finish used to be called
+// on first stage processors while (and again after) their goroutines ran.
+func TestFinishCalledOnce(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	for i := 0; i < 20; i++ {
+		source := &sourceCounter{finishCounter: finishCounter{concurrency: 4}, n: 50}
+		next := &finishCounter{concurrency: 4}
+		ratchettest.RunPipeline(t, ratchet.NewPipeline(context.Background(), nil, source, next, ratchettest.NewCapture()))
+		source.check(t, 50)
+		next.check(t, 50)
+
+		first := &finishCounter{concurrency: 4}
+		next = &finishCounter{concurrency: 4}
+		ratchettest.RunPipeline(t, ratchet.NewPipeline(context.Background(), nil, first, next, ratchettest.NewCapture()))
+		first.check(t, 1)
+		next.check(t, 1)
+	}
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/rhansen2/ratchet/data"
+)
+
+// Checkpoint records how far (in bytes) a transfer of each named object
+// has progressed, so an interrupted transfer can be resumed. Clear is
+// called once the transfer is complete.
+type Checkpoint interface {
+	Offset(name string) (int64, error)
+	SetOffset(name string, offset int64) error
+	Clear(name string) error
+}
+
+// MemoryCheckpoint is a Checkpoint that only lasts as long as the process.
+type MemoryCheckpoint struct {
+	offsets map[string]int64
+	sync.Mutex
+}
+
+// NewMemoryCheckpoint returns an empty MemoryCheckpoint.
+func NewMemoryCheckpoint() *MemoryCheckpoint {
+	return &MemoryCheckpoint{offsets: make(map[string]int64)}
+}
+
+// Offset returns the recorded offset for name, or 0 if there is none.
+func (c *MemoryCheckpoint) Offset(name string) (int64, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.offsets[name], nil
+}
+
+// SetOffset records the offset for name.
+func (c *MemoryCheckpoint) SetOffset(name string, offset int64) error {
+	c.Lock()
+	defer c.Unlock()
+	c.offsets[name] = offset
+	return nil
+}
+
+// Clear removes any recorded offset for name.
+func (c *MemoryCheckpoint) Clear(name string) error {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.offsets, name)
+	return nil
+}
+
+// FileCheckpoint is a Checkpoint persisted to a local JSON file, so
+// transfers can be resumed after a restart. The file is rewritten
+// (via a rename, so it's never left half-written) on every SetOffset.
+type FileCheckpoint struct {
+	MemoryCheckpoint
+	path string
+}
+
+// NewFileCheckpoint loads the checkpoint file at path, if it exists.
+func NewFileCheckpoint(path string) (*FileCheckpoint, error) {
+	c := &FileCheckpoint{MemoryCheckpoint: MemoryCheckpoint{offsets: make(map[string]int64)}, path: path}
+	d, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := data.ParseJSON(d, &c.offsets); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetOffset records the offset for name and writes the checkpoint file.
+func (c *FileCheckpoint) SetOffset(name string, offset int64) error {
+	c.Lock()
+	defer c.Unlock()
+	c.offsets[name] = offset
+	return c.write()
+}
+
+// Clear removes any recorded offset for name and writes the checkpoint file.
+func (c *FileCheckpoint) Clear(name string) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.offsets[name]; !ok {
+		return nil
+	}
+	delete(c.offsets, name)
+	return c.write()
+}
+
+func (c *FileCheckpoint) write() error {
+	d, err := data.NewJSON(c.offsets)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, d, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,56 @@
+package util_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhansen2/ratchet/util"
+)
+
+func TestFileCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+
+	c, err := util.NewFileCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOffset(t, c, "a.csv", 0)
+	if err := c.SetOffset("a.csv", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetOffset("b.csv", 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Clear("b.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Offsets are remembered across restarts, but cleared ones aren't.
+	c, err = util.NewFileCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOffset(t, c, "a.csv", 10)
+	assertOffset(t, c, "b.csv", 0)
+	if err := c.Clear("c.csv"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertOffset(t *testing.T, c util.Checkpoint, name string, want int64) {
+	t.Helper()
+	offset, err := c.Offset(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != want {
+		t.Errorf("Offset(%q) = %v, want %v", name, offset, want)
+	}
+}
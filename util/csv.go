@@ -30,14 +30,21 @@ type CSVParameters struct {
 	Header        []string
 	SendUpstream  bool
 	QuoteEscape   string
+	NullValue     string // Written in place of nil values, e.g. "NULL" or `\N`
 }
 
 // CSVProcess writes the contents to the file and optionally sends the written bytes
 // upstream on outputChan
 func CSVProcess(params *CSVParameters, d data.JSON, outputChan chan data.JSON, killChan chan error, ctx context.Context) {
 	objects, err := data.ObjectsFromJSON(d)
-	KillPipelineIfErr(err, killChan, ctx)
+	if err != nil {
+		KillPipelineIfErr(err, killChan, ctx)
+		return
+	}
 
+	if params.Header == nil && params.Writer.FixedWidth != nil {
+		params.Header = params.Writer.FixedWidth.Names()
+	}
 	if params.Header == nil {
 		for k := range objects[0] {
 			params.Header = append(params.Header, k)
@@ -60,17 +67,35 @@ func CSVProcess(params *CSVParameters, d data.JSON, outputChan chan data.JSON, k
 		row := []string{}
 		for i := range params.Header {
 			v := object[params.Header[i]]
-			row = append(row, CSVString(v))
+			if v == nil {
+				row = append(row, params.NullValue)
+			} else {
+				row = append(row, CSVString(v))
+			}
 		}
 		rows = append(rows, row)
 	}
 
+	if params.Writer.FixedWidth != nil {
+		// The header may name the layout's columns in any order.
+		for i := range rows {
+			rows[i], err = params.Writer.FixedWidth.Order(params.Header, rows[i])
+			if err != nil {
+				KillPipelineIfErr(err, killChan, ctx)
+				return
+			}
+		}
+	}
+
 	if params.SendUpstream {
 		var b bytes.Buffer
 		params.Writer.SetWriter(bufio.NewWriter(&b))
 
 		err = params.Writer.WriteAll(rows)
-		KillPipelineIfErr(err, killChan, ctx)
+		if err != nil {
+			KillPipelineIfErr(err, killChan, ctx)
+			return
+		}
 
 		outputChan <- []byte(b.String())
 	} else {
@@ -0,0 +1,47 @@
+package util_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rhansen2/ratchet/data"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+func TestCSVProcessFixedWidthHeader(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	w := util.NewCSVWriter()
+	w.FixedWidth = accountLayout
+	params := &util.CSVParameters{
+		Writer:       w,
+		WriteHeader:  true,
+		Header:       []string{"name", "id"},
+		SendUpstream: true,
+		NullValue:    "-",
+	}
+	outputChan := make(chan data.JSON, 1)
+	killChan := make(chan error, 1)
+	d := data.JSON(`[{"id": 7, "name": "Ada", "balance": 1}, {"id": 8, "name": null}]`)
+	util.CSVProcess(params, d, outputChan, killChan, context.Background())
+
+	want := "000idname            \n" +
+		"00007Ada             \n" +
+		"00008-               \n"
+	select {
+	case err := <-killChan:
+		t.Fatal(err)
+	case got := <-outputChan:
+		if string(got) != want {
+			t.Errorf("wrote %q, want %q", got, want)
+		}
+	}
+
+	params.Header = []string{"id", "email"}
+	util.CSVProcess(params, d, outputChan, killChan, context.Background())
+	select {
+	case <-killChan:
+	case got := <-outputChan:
+		t.Errorf("wrote %q for a header that isn't in the layout", got)
+	}
+}
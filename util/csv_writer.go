@@ -9,19 +9,24 @@ import (
 	"unicode/utf8"
 )
 
-// CSVWriter reimplements the standard library csv.Writer adding AlwaysEncapsulate and QuoteEscape
+// CSVWriter reimplements the standard library csv.Writer adding AlwaysEncapsulate, QuoteEscape,
+// a configurable Quote character, and fixed-width output.
+// Comma can be set to any delimiter, e.g. '|' or '\t'.
 type CSVWriter struct {
 	Comma             rune
+	Quote             rune // Character to encapsulate fields with
 	UseCRLF           bool
 	w                 *bufio.Writer
-	AlwaysEncapsulate bool   // If the content should be encapsulated independent of its type
-	QuoteEscape       string // String to use to escape a quote character
+	AlwaysEncapsulate bool             // If the content should be encapsulated independent of its type
+	QuoteEscape       string           // String to use to escape a quote character
+	FixedWidth        FixedWidthLayout // If set (and valid), records are written as fixed-width columns, in layout order, instead of delimited fields
 }
 
 // NewCSVWriter instantiates a new instance of CSVWriter
 func NewCSVWriter() *CSVWriter {
 	return &CSVWriter{
 		Comma:             ',',
+		Quote:             '"',
 		UseCRLF:           false,
 		AlwaysEncapsulate: true,
 		QuoteEscape:       `\`,
@@ -36,6 +41,13 @@ func (w *CSVWriter) SetWriter(writer io.Writer) {
 // Write writes a single CSV record to w along with any necessary quoting.
 // A record is a slice of strings with each string being one field.
 func (w *CSVWriter) Write(record []string) (err error) {
+	if w.FixedWidth != nil {
+		if _, err = w.w.WriteString(w.FixedWidth.Format(record)); err != nil {
+			return
+		}
+		return w.writeNewline()
+	}
+
 	for n, field := range record {
 		if n > 0 {
 			if _, err = w.w.WriteRune(w.Comma); err != nil {
@@ -49,14 +61,14 @@ func (w *CSVWriter) Write(record []string) (err error) {
 			}
 			continue
 		}
-		if err = w.w.WriteByte('"'); err != nil {
+		if _, err = w.w.WriteRune(w.Quote); err != nil {
 			return
 		}
 
 		for _, r1 := range field {
 			switch r1 {
-			case '"':
-				_, err = w.w.WriteString(fmt.Sprintf(`%v"`, w.QuoteEscape))
+			case w.Quote:
+				_, err = w.w.WriteString(fmt.Sprintf(`%v%c`, w.QuoteEscape, w.Quote))
 			case '\r':
 				if !w.UseCRLF {
 					err = w.w.WriteByte('\r')
@@ -75,17 +87,20 @@ func (w *CSVWriter) Write(record []string) (err error) {
 			}
 		}
 
-		if err = w.w.WriteByte('"'); err != nil {
+		if _, err = w.w.WriteRune(w.Quote); err != nil {
 			return
 		}
 	}
 
+	return w.writeNewline()
+}
+
+func (w *CSVWriter) writeNewline() (err error) {
 	if w.UseCRLF {
 		_, err = w.w.WriteString("\r\n")
 	} else {
 		err = w.w.WriteByte('\n')
 	}
-
 	return
 }
 
@@ -131,7 +146,7 @@ func (w *CSVWriter) fieldNeedsQuotes(field string) bool {
 	if field == "" {
 		return false
 	}
-	if field == `\.` || strings.IndexRune(field, w.Comma) >= 0 || strings.IndexAny(field, "\"\r\n") >= 0 {
+	if field == `\.` || strings.IndexRune(field, w.Comma) >= 0 || strings.IndexRune(field, w.Quote) >= 0 || strings.IndexAny(field, "\r\n") >= 0 {
 		return true
 	}
 
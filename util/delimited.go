@@ -0,0 +1,128 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrUnterminatedQuote is returned by DelimitedReader when the
+// input ends in the middle of a quoted field.
+var ErrUnterminatedQuote = errors.New("delimited: unterminated quoted field")
+
+// DelimitedReader reads records from delimited text. It is similar to the
+// standard library csv.Reader, but with a configurable quote and escape
+// character so that pipe, tab, and other non-RFC 4180 feeds can be read.
+type DelimitedReader struct {
+	Comma  rune // Field delimiter, e.g. '|' or '\t'. Defaults to ','.
+	Quote  rune // Quote character, defaults to '"'. Set to 0 to disable quoting.
+	Escape rune // Escapes a Quote within a quoted field. Defaults to Quote (doubled quotes), '\\' is also common.
+	r      *bufio.Reader
+}
+
+// NewDelimitedReader returns a new DelimitedReader reading from r, configured for standard CSV.
+func NewDelimitedReader(r io.Reader) *DelimitedReader {
+	return &DelimitedReader{
+		Comma:  ',',
+		Quote:  '"',
+		Escape: '"',
+		r:      bufio.NewReader(r),
+	}
+}
+
+// ReadAll reads all the remaining records from r.
+func (r *DelimitedReader) ReadAll() ([][]string, error) {
+	records := [][]string{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// Read reads one record (a slice of fields) from r. Empty lines are skipped.
+// If there are no more records, Read returns nil, io.EOF.
+func (r *DelimitedReader) Read() ([]string, error) {
+	for {
+		record, err := r.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 1 && record[0] == "" {
+			continue
+		}
+		return record, nil
+	}
+}
+
+func (r *DelimitedReader) readRecord() ([]string, error) {
+	record := []string{}
+	var field strings.Builder
+	quoted := false
+	fieldStart := true
+	readAny := false
+	for {
+		c, _, err := r.r.ReadRune()
+		if err == io.EOF {
+			if quoted {
+				return nil, ErrUnterminatedQuote
+			}
+			if !readAny {
+				return nil, io.EOF
+			}
+			return append(record, field.String()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		readAny = true
+
+		if quoted {
+			switch {
+			case c == r.Escape && r.Escape != r.Quote:
+				next, _, err := r.r.ReadRune()
+				if err != nil {
+					return nil, ErrUnterminatedQuote
+				}
+				field.WriteRune(next)
+			case c == r.Quote:
+				if r.Escape == r.Quote {
+					next, _, err := r.r.ReadRune()
+					if err == nil && next == r.Quote {
+						field.WriteRune(r.Quote)
+						continue
+					}
+					if err == nil {
+						r.r.UnreadRune()
+					}
+				}
+				quoted = false
+			default:
+				field.WriteRune(c)
+			}
+			continue
+		}
+
+		switch {
+		case fieldStart && r.Quote != 0 && c == r.Quote:
+			quoted = true
+			fieldStart = false
+		case c == r.Comma:
+			record = append(record, field.String())
+			field.Reset()
+			fieldStart = true
+		case c == '\n':
+			s := field.String()
+			return append(record, strings.TrimSuffix(s, "\r")), nil
+		default:
+			field.WriteRune(c)
+			fieldStart = false
+		}
+	}
+}
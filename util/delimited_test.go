@@ -0,0 +1,57 @@
+package util_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rhansen2/ratchet/util"
+)
+
+func ExampleDelimitedReader() {
+	r := util.NewDelimitedReader(strings.NewReader("id|name\n1|\"Smith | Sons\"\n"))
+	r.Comma = '|'
+	records, err := r.ReadAll()
+	fmt.Printf("%q %v\n", records, err)
+	// Output: [["id" "name"] ["1" "Smith | Sons"]] <nil>
+}
+
+func TestDelimitedReader(t *testing.T) {
+	tests := []struct {
+		input  string
+		comma  rune
+		quote  rune
+		escape rune
+		want   [][]string
+	}{
+		{"a,b\r\n\n\"c,d\",\"e\"\"f\"\n", ',', '"', '"', [][]string{{"a", "b"}, {"c,d", `e"f`}}},
+		{"a\tb\t\nc\t\"d\te\"", '\t', '"', '"', [][]string{{"a", "b", ""}, {"c", "d\te"}}},
+		{`'a\'b'|c\d`, '|', '\'', '\\', [][]string{{"a'b", `c\d`}}},
+		{"\"line\none\",2\n", ',', '"', '"', [][]string{{"line\none", "2"}}},
+		{"a,\"b\"c\n", ',', 0, 0, [][]string{{"a", `"b"c`}}},
+		{"", ',', '"', '"', [][]string{}},
+	}
+	for _, test := range tests {
+		r := util.NewDelimitedReader(strings.NewReader(test.input))
+		r.Comma, r.Quote, r.Escape = test.comma, test.quote, test.escape
+		got, err := r.ReadAll()
+		if err != nil {
+			t.Errorf("ReadAll(%q) returned error %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ReadAll(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestDelimitedReaderUnterminatedQuote(t *testing.T) {
+	for _, input := range []string{"a,\"b\n", `"a\`} {
+		r := util.NewDelimitedReader(strings.NewReader(input))
+		r.Escape = '\\'
+		if _, err := r.ReadAll(); err != util.ErrUnterminatedQuote {
+			t.Errorf("ReadAll(%q) returned error %v, want %v", input, err, util.ErrUnterminatedQuote)
+		}
+	}
+}
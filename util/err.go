@@ -2,12 +2,22 @@ package util
 
 import (
 	"context"
+	"errors"
 
 	"github.com/rhansen2/ratchet/logger"
 )
 
-// KillPipelineIfErr is an error-checking helper.
+// KillPipelineIfErr is an error-checking helper. Errors caused by ctx being
+// done (i.e. ctx.Err()) aren't sent, since they're expected when a Pipeline
+// is cancelled or shut down.
+//
+// KillPipelineIfErr doesn't stop the caller, so callers should return after
+// passing it a non-nil error: with the CollectErrors ErrorPolicy, the Pipeline
+// keeps running after the error is sent.
 func KillPipelineIfErr(err error, killChan chan error, ctx context.Context) {
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return
+	}
 	if err != nil {
 		logger.Error(err.Error())
 		select {
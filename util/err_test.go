@@ -0,0 +1,33 @@
+package util_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+)
+
+func TestKillPipelineIfErr(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	killChan := make(chan error, 1)
+	util.KillPipelineIfErr(nil, killChan, context.Background())
+	if len(killChan) != 0 {
+		t.Fatal("nil error was sent")
+	}
+
+	util.KillPipelineIfErr(errors.New("failed"), killChan, context.Background())
+	if err := <-killChan; err.Error() != "failed" {
+		t.Errorf("sent %v", err)
+	}
+
+	// Errors caused by cancelling ctx aren't sent.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	util.KillPipelineIfErr(fmt.Errorf("reading: %w", ctx.Err()), killChan, ctx)
+	if len(killChan) != 0 {
+		t.Errorf("sent %v", <-killChan)
+	}
+}
@@ -0,0 +1,152 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// FixedWidthField defines a single column of a FixedWidthLayout.
+type FixedWidthField struct {
+	Name       string `json:"name"`
+	Start      int    `json:"start"`       // Zero-based offset (in characters) where the column begins.
+	Width      int    `json:"width"`       // Width of the column in characters.
+	RightAlign bool   `json:"right_align"` // When writing, pad on the left instead of the right (e.g. for numbers).
+	Pad        string `json:"pad"`         // When writing, the character to pad with. Defaults to a space.
+}
+
+// FixedWidthLayout describes a fixed-width (e.g. mainframe-style) record,
+// where each column is found at a fixed character offset. Use
+// NewFixedWidthLayout (or Validate) to check a layout before it's used,
+// since Parse and Format panic on a column with a negative Start or Width.
+type FixedWidthLayout []FixedWidthField
+
+// NewFixedWidthLayout returns a FixedWidthLayout of the given columns,
+// or an error if they aren't valid (see Validate).
+func NewFixedWidthLayout(fields ...FixedWidthField) (FixedWidthLayout, error) {
+	l := FixedWidthLayout(fields)
+	return l, l.Validate()
+}
+
+// Validate checks that the layout has at least one column, and that each
+// column has a unique name, a Start of at least zero, a Width of at least
+// one, and a Pad of at most one character. Columns may overlap.
+func (l FixedWidthLayout) Validate() error {
+	if len(l) == 0 {
+		return fmt.Errorf("fixed-width layout has no columns")
+	}
+	names := make(map[string]bool, len(l))
+	for i, f := range l {
+		switch {
+		case f.Name == "":
+			return fmt.Errorf("fixed-width column %d has no name", i)
+		case names[f.Name]:
+			return fmt.Errorf("fixed-width column %q is defined twice", f.Name)
+		case f.Start < 0:
+			return fmt.Errorf("fixed-width column %q has a negative start (%d)", f.Name, f.Start)
+		case f.Width < 1:
+			return fmt.Errorf("fixed-width column %q has a width of %d, must be at least 1", f.Name, f.Width)
+		case utf8.RuneCountInString(f.Pad) > 1:
+			return fmt.Errorf("fixed-width column %q pad %q must be a single character", f.Name, f.Pad)
+		}
+		names[f.Name] = true
+	}
+	return nil
+}
+
+// Names returns the column names in layout order.
+func (l FixedWidthLayout) Names() []string {
+	names := make([]string, len(l))
+	for i, f := range l {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Parse splits a single line into its columns. Surrounding spaces are trimmed
+// from each value and, if nullValue isn't empty, values equal to it are set
+// to nil. Columns past the end of a short line are treated as empty.
+func (l FixedWidthLayout) Parse(line string, nullValue string) map[string]interface{} {
+	runes := []rune(strings.TrimRight(line, "\r\n"))
+	object := make(map[string]interface{}, len(l))
+	for _, f := range l {
+		v := ""
+		if f.Start < len(runes) {
+			end := f.Start + f.Width
+			if end > len(runes) {
+				end = len(runes)
+			}
+			v = strings.TrimSpace(string(runes[f.Start:end]))
+		}
+		if nullValue != "" && v == nullValue {
+			object[f.Name] = nil
+		} else {
+			object[f.Name] = v
+		}
+	}
+	return object
+}
+
+// Format builds a single line from the given values (in layout order),
+// padding or truncating each value to its column width. Gaps between
+// columns are filled with spaces. See Order for values in another order.
+func (l FixedWidthLayout) Format(values []string) string {
+	length := 0
+	for _, f := range l {
+		if f.Start+f.Width > length {
+			length = f.Start + f.Width
+		}
+	}
+	line := []rune(strings.Repeat(" ", length))
+	for i, f := range l {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		copy(line[f.Start:f.Start+f.Width], []rune(f.pad(v)))
+	}
+	return string(line)
+}
+
+// Order rearranges values given in the order of the named columns (e.g. a
+// CSVParameters.Header) into layout order, as expected by Format. Columns
+// that aren't named are left blank, and an error is returned for names that
+// aren't in the layout.
+func (l FixedWidthLayout) Order(names []string, values []string) ([]string, error) {
+	ordered := make([]string, len(l))
+	for i, name := range names {
+		j := l.index(name)
+		if j < 0 {
+			return nil, fmt.Errorf("column %q isn't in the fixed-width layout", name)
+		}
+		if i < len(values) {
+			ordered[j] = values[i]
+		}
+	}
+	return ordered, nil
+}
+
+func (l FixedWidthLayout) index(name string) int {
+	for i, f := range l {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f FixedWidthField) pad(v string) string {
+	runes := []rune(v)
+	if len(runes) >= f.Width {
+		return string(runes[:f.Width])
+	}
+	pad := " "
+	if r, _ := utf8.DecodeRuneInString(f.Pad); f.Pad != "" {
+		pad = string(r)
+	}
+	padding := strings.Repeat(pad, f.Width-len(runes))
+	if f.RightAlign {
+		return padding + v
+	}
+	return v + padding
+}
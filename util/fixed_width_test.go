@@ -0,0 +1,83 @@
+package util_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rhansen2/ratchet/util"
+)
+
+var accountLayout = util.FixedWidthLayout{
+	{Name: "id", Start: 0, Width: 5, RightAlign: true, Pad: "0"},
+	{Name: "name", Start: 5, Width: 8},
+	{Name: "balance", Start: 15, Width: 6, RightAlign: true},
+}
+
+func ExampleFixedWidthLayout() {
+	fmt.Printf("%q\n", accountLayout.Format([]string{"42", "Ada Lovelace", "10.50"}))
+	fmt.Println(accountLayout.Parse("00042Ada Love   10.50", ""))
+	// Output:
+	// "00042Ada Love   10.50"
+	// map[balance:10.50 id:00042 name:Ada Love]
+}
+
+func TestFixedWidthLayoutParse(t *testing.T) {
+	tests := []struct {
+		line      string
+		nullValue string
+		want      map[string]interface{}
+	}{
+		{"00042Ada Love   10.50\r\n", "", map[string]interface{}{"id": "00042", "name": "Ada Love", "balance": "10.50"}},
+		{"00042Ada", "", map[string]interface{}{"id": "00042", "name": "Ada", "balance": ""}},
+		{"", "", map[string]interface{}{"id": "", "name": "", "balance": ""}},
+		{"00042N/A        N/A", "N/A", map[string]interface{}{"id": "00042", "name": nil, "balance": nil}},
+	}
+	for _, test := range tests {
+		got := accountLayout.Parse(test.line, test.nullValue)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Parse(%q, %q) = %v, want %v", test.line, test.nullValue, got, test.want)
+		}
+	}
+}
+
+func TestFixedWidthLayoutOrder(t *testing.T) {
+	values, err := accountLayout.Order([]string{"balance", "id"}, []string{"1.00", "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := accountLayout.Format(values); got != "00007            1.00" {
+		t.Errorf("Format(%q) = %q", values, got)
+	}
+	if _, err := accountLayout.Order([]string{"id", "email"}, []string{"7", "a@example.com"}); err == nil {
+		t.Error("no error for a column that isn't in the layout")
+	}
+}
+
+func TestFixedWidthLayoutValidate(t *testing.T) {
+	tests := []struct {
+		fields []util.FixedWidthField
+		err    string
+	}{
+		{[]util.FixedWidthField{{Name: "a", Start: 0, Width: 1}, {Name: "b", Start: 0, Width: 3}}, ""},
+		{nil, "no columns"},
+		{[]util.FixedWidthField{{Start: 0, Width: 1}}, "has no name"},
+		{[]util.FixedWidthField{{Name: "a", Start: 0, Width: 1}, {Name: "a", Start: 1, Width: 1}}, "defined twice"},
+		{[]util.FixedWidthField{{Name: "a", Start: -1, Width: 1}}, "negative start"},
+		{[]util.FixedWidthField{{Name: "a", Start: 0, Width: 0}}, "width of 0"},
+		{[]util.FixedWidthField{{Name: "a", Start: 0, Width: 1, Pad: "ab"}}, "single character"},
+	}
+	for _, test := range tests {
+		_, err := util.NewFixedWidthLayout(test.fields...)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("NewFixedWidthLayout(%v) returned error %v", test.fields, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("NewFixedWidthLayout(%v) returned error %v, want %q", test.fields, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"github.com/rhansen2/ratchet/logger"
+	"google.golang.org/api/iterator"
+)
+
+// ListGCSObjects returns the names of all objects in the given Google Cloud
+// Storage bucket matching the given prefix. Like ListS3Objects, delimiter is
+// set to "/", so objects in "subdirectories" of the prefix aren't included.
+func ListGCSObjects(client *storage.Client, bucket, prefix string, ctx context.Context) ([]string, error) {
+	logger.Debug("ListGCSObjects: ", bucket, "-", prefix)
+	it := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix, Delimiter: "/"})
+	objects := []string{}
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Prefixes (i.e. "subdirectories") are listed without a name.
+		if attrs.Name != "" {
+			objects = append(objects, attrs.Name)
+		}
+	}
+	return objects, nil
+}
+
+// DeleteGCSObjects deletes the given objects. Google Cloud Storage has no
+// batch delete, so each object is deleted in turn, stopping at the first error.
+func DeleteGCSObjects(client *storage.Client, bucket string, names []string, ctx context.Context) error {
+	logger.Debug("DeleteGCSObjects: ", bucket, "-", names)
+	b := client.Bucket(bucket)
+	for _, name := range names {
+		if err := b.Object(name).Delete(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"bufio"
+	"os"
+	"sync"
+)
+
+// Ledger keeps track of which files (or other named objects) have
+// already been processed, so long-running readers don't send them twice.
+type Ledger interface {
+	Processed(name string) (bool, error)
+	MarkProcessed(name string) error
+}
+
+// MemoryLedger is a Ledger that only lasts as long as the process.
+type MemoryLedger struct {
+	processed map[string]struct{}
+	sync.Mutex
+}
+
+// NewMemoryLedger returns an empty MemoryLedger.
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{processed: make(map[string]struct{})}
+}
+
+// Processed returns true if name has been marked as processed.
+func (l *MemoryLedger) Processed(name string) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	_, ok := l.processed[name]
+	return ok, nil
+}
+
+// MarkProcessed records name as processed.
+func (l *MemoryLedger) MarkProcessed(name string) error {
+	l.Lock()
+	defer l.Unlock()
+	l.processed[name] = struct{}{}
+	return nil
+}
+
+// FileLedger is a Ledger persisted to a local file, one name per line,
+// so that processed files are remembered across restarts.
+type FileLedger struct {
+	MemoryLedger
+	file *os.File
+}
+
+// NewFileLedger opens (or creates) the ledger file at path and
+// loads any names previously marked as processed.
+func NewFileLedger(path string) (*FileLedger, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	l := &FileLedger{MemoryLedger: MemoryLedger{processed: make(map[string]struct{})}, file: f}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l.processed[scanner.Text()] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// MarkProcessed records name as processed and syncs it to the ledger file.
+func (l *FileLedger) MarkProcessed(name string) error {
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.processed[name]; ok {
+		return nil
+	}
+	if _, err := l.file.WriteString(name + "\n"); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.processed[name] = struct{}{}
+	return nil
+}
+
+// Close closes the underlying ledger file.
+func (l *FileLedger) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,68 @@
+package util_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhansen2/ratchet/util"
+)
+
+func TestMemoryLedger(t *testing.T) {
+	l := util.NewMemoryLedger()
+	assertProcessed(t, l, "a.csv", false)
+	if err := l.MarkProcessed("a.csv"); err != nil {
+		t.Fatal(err)
+	}
+	assertProcessed(t, l, "a.csv", true)
+	assertProcessed(t, l, "b.csv", false)
+}
+
+func TestFileLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ledger")
+
+	l, err := util.NewFileLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.csv", "b.csv", "a.csv"} {
+		if err := l.MarkProcessed(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	// Processed names are remembered across restarts, and only written once.
+	l, err = util.NewFileLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	assertProcessed(t, l, "a.csv", true)
+	assertProcessed(t, l, "b.csv", true)
+	assertProcessed(t, l, "c.csv", false)
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "a.csv\nb.csv\n" {
+		t.Errorf("ledger file is %q", contents)
+	}
+}
+
+func assertProcessed(t *testing.T, l util.Ledger, name string, want bool) {
+	t.Helper()
+	processed, err := l.Processed(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != want {
+		t.Errorf("Processed(%q) = %v, want %v", name, processed, want)
+	}
+}
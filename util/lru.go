@@ -0,0 +1,73 @@
+package util
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRUCache is a fixed size cache, safe for concurrent use, which evicts the
+// least recently used entries once full. Entries can also expire after a TTL.
+type LRUCache struct {
+	size    int
+	ttl     time.Duration
+	entries *list.List
+	items   map[string]*list.Element
+	sync.Mutex
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// NewLRUCache returns an LRUCache holding up to size entries, which
+// expire after ttl (or never, if ttl is 0).
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{size: size, ttl: ttl, entries: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get returns the value cached for key, if there's one that hasn't expired.
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.entries.Remove(e)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.entries.MoveToFront(e)
+	return entry.value, true
+}
+
+// Add caches value for key, evicting the least recently used entry if full.
+func (c *LRUCache) Add(key string, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	entry := &lruEntry{key: key, value: value, expires: time.Now().Add(c.ttl)}
+	if e, ok := c.items[key]; ok {
+		e.Value = entry
+		c.entries.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.entries.PushFront(entry)
+	for c.entries.Len() > c.size {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of entries cached, including any that have expired
+// but haven't been evicted yet.
+func (c *LRUCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.entries.Len()
+}
@@ -0,0 +1,63 @@
+package util_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rhansen2/ratchet/util"
+)
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := util.NewLRUCache(2, 0)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	// Using a makes b the least recently used.
+	assertCached(t, c, "a", 1)
+	c.Add("c", 3)
+	if c.Len() != 2 {
+		t.Errorf("got %d entries, want 2", c.Len())
+	}
+	assertCached(t, c, "a", 1)
+	assertNotCached(t, c, "b")
+	assertCached(t, c, "c", 3)
+
+	// Replacing a value doesn't evict anything, but makes it most recently used.
+	c.Add("a", 4)
+	c.Add("d", 5)
+	assertCached(t, c, "a", 4)
+	assertNotCached(t, c, "c")
+	assertCached(t, c, "d", 5)
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	c := util.NewLRUCache(10, 20*time.Millisecond)
+	c.Add("a", 1)
+	assertCached(t, c, "a", 1)
+	time.Sleep(40 * time.Millisecond)
+	c.Add("b", 2)
+	assertNotCached(t, c, "a")
+	assertCached(t, c, "b", 2)
+	if c.Len() != 1 {
+		t.Errorf("got %d entries, want the expired entry removed", c.Len())
+	}
+
+	// Adding a key again restarts its TTL.
+	time.Sleep(15 * time.Millisecond)
+	c.Add("b", 3)
+	time.Sleep(15 * time.Millisecond)
+	assertCached(t, c, "b", 3)
+}
+
+func assertCached(t *testing.T, c *util.LRUCache, key string, want interface{}) {
+	t.Helper()
+	if v, ok := c.Get(key); !ok || v != want {
+		t.Errorf("Get(%q) = %v, %v, want %v, true", key, v, ok, want)
+	}
+}
+
+func assertNotCached(t *testing.T, c *util.LRUCache, key string) {
+	t.Helper()
+	if v, ok := c.Get(key); ok {
+		t.Errorf("Get(%q) = %v, want no entry", key, v)
+	}
+}
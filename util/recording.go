@@ -0,0 +1,119 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// recordingMagic starts every recording, followed by a format version.
+var recordingMagic = []byte("RCHT\x01")
+
+// ErrNotRecording is returned by NewRecordingReader for input that isn't a recording.
+var ErrNotRecording = errors.New("recording: not a ratchet recording")
+
+// RecordingWriter writes timestamped payloads in a compact binary log, to
+// be read back with a RecordingReader. Each record is the time since the
+// previous record (in nanoseconds) and the payload's length, as varints,
+// followed by the payload itself.
+type RecordingWriter struct {
+	w    *bufio.Writer
+	last time.Time
+	buf  [2 * binary.MaxVarintLen64]byte
+}
+
+// NewRecordingWriter writes the header of a recording started at start to w.
+func NewRecordingWriter(w io.Writer, start time.Time) (*RecordingWriter, error) {
+	rw := &RecordingWriter{w: bufio.NewWriter(w), last: start}
+	if _, err := rw.w.Write(recordingMagic); err != nil {
+		return nil, err
+	}
+	n := binary.PutVarint(rw.buf[:], start.UnixNano())
+	if _, err := rw.w.Write(rw.buf[:n]); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// Write records d as sent at t.
+func (w *RecordingWriter) Write(t time.Time, d []byte) error {
+	delta := t.Sub(w.last)
+	if delta < 0 {
+		delta = 0
+	}
+	w.last = w.last.Add(delta)
+	n := binary.PutUvarint(w.buf[:], uint64(delta))
+	n += binary.PutUvarint(w.buf[n:], uint64(len(d)))
+	if _, err := w.w.Write(w.buf[:n]); err != nil {
+		return err
+	}
+	_, err := w.w.Write(d)
+	return err
+}
+
+// Flush writes any buffered records to the underlying io.Writer.
+func (w *RecordingWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// RecordingReader reads the payloads written by a RecordingWriter.
+type RecordingReader struct {
+	r     *bufio.Reader
+	start time.Time
+	last  time.Time
+}
+
+// NewRecordingReader reads the header of the recording in r,
+// which may be gzip compressed.
+func NewRecordingReader(r io.Reader) (*RecordingReader, error) {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(2); err == nil && b[0] == 0x1f && b[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gz)
+	}
+
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, recordingMagic) {
+		return nil, ErrNotRecording
+	}
+	start, err := binary.ReadVarint(br)
+	if err != nil {
+		return nil, ErrNotRecording
+	}
+	t := time.Unix(0, start)
+	return &RecordingReader{r: br, start: t, last: t}, nil
+}
+
+// Start returns when the recording was started.
+func (r *RecordingReader) Start() time.Time {
+	return r.start
+}
+
+// Read returns the next payload and the time it was recorded. If there
+// are no more payloads, Read returns io.EOF. A recording cut off part way
+// through a payload, or a gzip compressed recording missing its trailer
+// (e.g. after a crash), returns io.ErrUnexpectedEOF once the complete
+// payloads have been read.
+func (r *RecordingReader) Read() (time.Time, []byte, error) {
+	delta, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	length, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return time.Time{}, nil, io.ErrUnexpectedEOF
+	}
+	var d bytes.Buffer
+	if n, err := io.CopyN(&d, r.r, int64(length)); err != nil || n != int64(length) {
+		return time.Time{}, nil, io.ErrUnexpectedEOF
+	}
+	r.last = r.last.Add(time.Duration(delta))
+	return r.last, d.Bytes(), nil
+}
@@ -0,0 +1,166 @@
+package util_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rhansen2/ratchet/util"
+)
+
+var recordingStart = time.Unix(1500000000, 0)
+
+type record struct {
+	t time.Time
+	d string
+}
+
+var records = []record{
+	{recordingStart.Add(10 * time.Millisecond), `{"a":1}`},
+	{recordingStart.Add(2 * time.Second), ``},
+	{recordingStart.Add(2 * time.Second), `[{"b":"` + string(bytes.Repeat([]byte("x"), 300)) + `"}]`},
+	// Out of order times are recorded as sent at the previous time.
+	{recordingStart.Add(time.Second), `{"c":3}`},
+}
+
+func writeRecording(t *testing.T, w io.Writer, records []record) {
+	t.Helper()
+	rw, err := util.NewRecordingWriter(w, recordingStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := rw.Write(r.t, []byte(r.d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readRecording reads every record, returning the error that ended the recording.
+func readRecording(t *testing.T, r io.Reader) ([]record, error) {
+	t.Helper()
+	rr, err := util.NewRecordingReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rr.Start().Equal(recordingStart) {
+		t.Errorf("got start %v, want %v", rr.Start(), recordingStart)
+	}
+	var got []record
+	for {
+		ts, d, err := rr.Read()
+		if err != nil {
+			return got, err
+		}
+		got = append(got, record{ts, string(d)})
+	}
+}
+
+func assertRecords(t *testing.T, got, want []record) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range got {
+		wantT := want[i].t
+		if i > 0 && wantT.Before(want[i-1].t) {
+			wantT = want[i-1].t
+		}
+		if !got[i].t.Equal(wantT) || got[i].d != want[i].d {
+			t.Errorf("record %d: got %v %q, want %v %q", i, got[i].t, got[i].d, wantT, want[i].d)
+		}
+	}
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writeRecording(t, &buf, records)
+	got, err := readRecording(t, &buf)
+	if err != io.EOF {
+		t.Errorf("got error %v, want io.EOF", err)
+	}
+	assertRecords(t, got, records)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	writeRecording(t, zw, records)
+	zw.Close()
+	got, err = readRecording(t, &gz)
+	if err != io.EOF {
+		t.Errorf("compressed: got error %v, want io.EOF", err)
+	}
+	assertRecords(t, got, records)
+}
+
+func TestRecordingEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	writeRecording(t, &buf, nil)
+	if got, err := readRecording(t, &buf); len(got) != 0 || err != io.EOF {
+		t.Errorf("got %v, %v, want no records and io.EOF", got, err)
+	}
+}
+
+func TestRecordingNotRecording(t *testing.T) {
+	for _, input := range []string{"", "RCHT", "RCHT\x02\x00", `{"a":1}`} {
+		if _, err := util.NewRecordingReader(bytes.NewReader([]byte(input))); err != util.ErrNotRecording {
+			t.Errorf("%q: got error %v, want ErrNotRecording", input, err)
+		}
+	}
+}
+
+// TestRecordingTruncated cuts a recording off at every length, checking the
+// complete records are read before io.ErrUnexpectedEOF (or io.EOF, for a
+// cut between records).
+func TestRecordingTruncated(t *testing.T) {
+	var full, header bytes.Buffer
+	writeRecording(t, &full, records)
+	writeRecording(t, &header, nil)
+	ends := make(map[int]int) // The length of the recording after each record.
+	for i := range records {
+		var buf bytes.Buffer
+		writeRecording(t, &buf, records[:i+1])
+		ends[buf.Len()] = i + 1
+	}
+
+	complete := 0
+	for n := header.Len(); n < full.Len(); n++ {
+		if end, ok := ends[n]; ok {
+			complete = end
+		}
+		got, err := readRecording(t, bytes.NewReader(full.Bytes()[:n]))
+		wantErr := io.ErrUnexpectedEOF
+		if _, ok := ends[n]; ok || n == header.Len() {
+			wantErr = io.EOF
+		}
+		if err != wantErr {
+			t.Errorf("cut at %d bytes: got error %v, want %v", n, err, wantErr)
+		}
+		assertRecords(t, got, records[:complete])
+	}
+}
+
+// TestRecordingCompressedCrash reads a gzip compressed recording that was
+// flushed, as a Recorder does, but never closed.
+func TestRecordingCompressedCrash(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	rw, err := util.NewRecordingWriter(zw, recordingStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		rw.Write(r.t, []byte(r.d))
+		rw.Flush()
+		zw.Flush()
+	}
+	got, err := readRecording(t, &buf)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v, want io.ErrUnexpectedEOF", err)
+	}
+	assertRecords(t, got, records)
+}
@@ -40,6 +40,29 @@ func ListS3Objects(client *s3.S3, bucket, keyPrefix string) ([]string, error) {
 	return objects, nil
 }
 
+// ListS3ObjectDetails is like ListS3Objects, but returns the full object
+// listing (including size and last modified time) for each matching key.
+func ListS3ObjectDetails(client *s3.S3, bucket, keyPrefix string) ([]*s3.Object, error) {
+	logger.Debug("ListS3ObjectDetails: ", bucket, "-", keyPrefix)
+	params := &s3.ListObjectsInput{
+		Bucket:    aws.String(bucket), // Required
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int64(1000),
+		Prefix:    aws.String(keyPrefix),
+	}
+
+	objects := []*s3.Object{}
+	err := client.ListObjectsPages(params, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		objects = append(objects, page.Contents...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
 // GetS3Object returns the object output for the given object key
 func GetS3Object(client *s3.S3, bucket, objKey string) (*s3.GetObjectOutput, error) {
 	logger.Debug("GetS3Object: ", bucket, "-", objKey)
@@ -67,6 +90,30 @@ func GetS3Object(client *s3.S3, bucket, objKey string) (*s3.GetObjectOutput, err
 	return client.GetObject(params)
 }
 
+// GetS3ObjectRange returns the object output for the given byte range
+// (inclusive of both start and end) of the given object key.
+func GetS3ObjectRange(client *s3.S3, bucket, objKey string, start, end int64) (*s3.GetObjectOutput, error) {
+	logger.Debug("GetS3ObjectRange: ", bucket, "-", objKey, start, "-", end)
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(bucket), // Required
+		Key:    aws.String(objKey), // Required
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+	}
+
+	return client.GetObject(params)
+}
+
+// HeadS3Object returns the metadata (size, ETag, etc.) for the given object key
+func HeadS3Object(client *s3.S3, bucket, objKey string) (*s3.HeadObjectOutput, error) {
+	logger.Debug("HeadS3Object: ", bucket, "-", objKey)
+	params := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket), // Required
+		Key:    aws.String(objKey), // Required
+	}
+
+	return client.HeadObject(params)
+}
+
 // DeleteS3Objects deletes the objects specified by the given object keys
 func DeleteS3Objects(client *s3.S3, bucket string, objKeys []string) (*s3.DeleteObjectsOutput, error) {
 	logger.Debug("DeleteS3Objects: ", bucket, "-", objKeys)
@@ -119,3 +166,19 @@ func WriteS3Object(data []string, config *aws.Config, bucket string, key string,
 
 	return result.Location, err
 }
+
+// UploadS3Object uploads the contents of body to the given key
+func UploadS3Object(config *aws.Config, bucket string, key string, body io.Reader) (string, error) {
+	uploader := s3manager.NewUploader(session.New(config))
+
+	result, err := uploader.Upload(&s3manager.UploadInput{
+		Body:   body,
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result.Location, nil
+}
@@ -1,11 +1,18 @@
 package util
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pkg/sftp"
+	"github.com/rhansen2/ratchet/logger"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // SftpParameters is used for storing connection parameters for later executing sftp commands
@@ -14,6 +21,29 @@ type SftpParameters struct {
 	Username    string
 	Path        string
 	AuthMethods []ssh.AuthMethod
+	SftpOptions
+}
+
+// SftpOptions configures how connections to an sftp server are verified and kept alive.
+type SftpOptions struct {
+	// HostKeyCallback verifies the server's host key, see SftpKnownHosts and
+	// SftpPinnedHostKey. If nil, the key must be in ~/.ssh/known_hosts.
+	HostKeyCallback ssh.HostKeyCallback
+	// InsecureIgnoreHostKey disables host key verification when HostKeyCallback
+	// is nil, accepting any server (as ratchet did before verification was added).
+	// It should only be used for testing.
+	InsecureIgnoreHostKey bool
+	// Timeout limits how long connecting (including the ssh handshake) may take.
+	Timeout time.Duration
+	// ReadTimeout drops the connection if nothing is received from the server
+	// for this long, rather than hanging on an unresponsive server.
+	ReadTimeout time.Duration
+	// KeepAlive is how often a keepalive request is sent to the server.
+	// It defaults to half of ReadTimeout, so idle connections aren't dropped.
+	KeepAlive time.Duration
+	// MaxReconnects is how many times SftpConnection.Retry re-establishes
+	// a dropped connection before giving up.
+	MaxReconnects int
 }
 
 // SftpPath is a simple struct for storing the full path of an object
@@ -26,21 +56,205 @@ func (t SftpPath) FileName() string {
 	return filepath.Base(t.Path)
 }
 
-// SftpClient sets up and return the client
+// SftpClient sets up and return the client. The server's host key must be in ~/.ssh/known_hosts;
+// to use another known_hosts file, a pinned key, or to disable verification
+// (SftpOptions.InsecureIgnoreHostKey), use SftpConnect.
 func SftpClient(server string, username string, authMethod []ssh.AuthMethod, opts ...sftp.ClientOption) (*sftp.Client, error) {
-	var client *sftp.Client
+	return SftpConnect(&SftpParameters{Server: server, Username: username, AuthMethods: authMethod}, opts...)
+}
 
+// SftpConnect sets up and returns a client using the given parameters and options.
+func SftpConnect(params *SftpParameters, opts ...sftp.ClientOption) (*sftp.Client, error) {
+	hostKeyCallback := params.HostKeyCallback
+	if hostKeyCallback == nil && params.InsecureIgnoreHostKey {
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	}
+	if hostKeyCallback == nil {
+		var err error
+		if hostKeyCallback, err = SftpKnownHosts(); err != nil {
+			return nil, err
+		}
+	}
 	config := &ssh.ClientConfig{
-		User: username,
-		Auth: authMethod,
+		User:            params.Username,
+		Auth:            params.AuthMethods,
+		HostKeyCallback: hostKeyCallback,
+	}
+
+	netConn, err := net.DialTimeout("tcp", params.Server, params.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if params.ReadTimeout > 0 {
+		netConn = &timeoutConn{Conn: netConn, timeout: params.ReadTimeout}
+		netConn.SetReadDeadline(time.Now().Add(params.ReadTimeout))
+	}
+	if params.Timeout > 0 {
+		// Abandon the handshake if it takes too long.
+		timer := time.AfterFunc(params.Timeout, func() { netConn.Close() })
+		defer timer.Stop()
+	}
+	c, chans, reqs, err := ssh.NewClientConn(netConn, params.Server, config)
+	if err != nil {
+		netConn.Close()
+		return nil, err
 	}
+	conn := ssh.NewClient(c, chans, reqs)
 
-	conn, err := ssh.Dial("tcp", server, config)
+	client, err := sftp.NewClient(conn, opts...)
 	if err != nil {
-		return client, err
+		conn.Close()
+		return nil, err
 	}
 
-	return sftp.NewClient(conn, opts...)
+	done := make(chan struct{})
+	go func() {
+		// Closing the sftp client only closes its session, so
+		// close the ssh connection once the session is gone.
+		client.Wait()
+		conn.Close()
+		close(done)
+	}()
+	keepAlive := params.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = params.ReadTimeout / 2
+	}
+	if keepAlive > 0 {
+		go sftpKeepAlive(conn, keepAlive, done)
+	}
+	return client, nil
+}
+
+func sftpKeepAlive(conn *ssh.Client, interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, _, err := conn.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				logger.Error("SftpConnect: keepalive failed -", err.Error())
+				conn.Close()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// timeoutConn extends the read deadline every time data is received, so the
+// connection is only dropped if the server stops responding.
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	return n, err
+}
+
+// SftpKnownHosts returns a HostKeyCallback that verifies host keys against the
+// given known_hosts files, or ~/.ssh/known_hosts if none are given.
+func SftpKnownHosts(files ...string) (ssh.HostKeyCallback, error) {
+	if len(files) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		files = []string{filepath.Join(home, ".ssh", "known_hosts")}
+	}
+	return knownhosts.New(files...)
+}
+
+// SftpPinnedHostKey returns a HostKeyCallback that only accepts a single host key.
+// The key can be given in authorized_keys format (e.g. "ssh-ed25519 AAAA...") or as
+// a SHA256 fingerprint (e.g. "SHA256:...", as printed by ssh-keygen -l).
+func SftpPinnedHostKey(key string) (ssh.HostKeyCallback, error) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "SHA256:") {
+		return func(hostname string, remote net.Addr, k ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(k) != key {
+				return fmt.Errorf("sftp: host key for %v has fingerprint %v, expected %v", hostname, ssh.FingerprintSHA256(k), key)
+			}
+			return nil
+		}, nil
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	return ssh.FixedHostKey(pub), nil
+}
+
+// SftpConnection manages a client connection, re-establishing it if it's dropped.
+type SftpConnection struct {
+	params *SftpParameters
+	opts   []sftp.ClientOption
+	client *sftp.Client
+}
+
+// NewSftpConnection returns a new SftpConnection. The connection to the remote
+// server is delayed until Client or Retry is called.
+func NewSftpConnection(params *SftpParameters, opts ...sftp.ClientOption) *SftpConnection {
+	return &SftpConnection{params: params, opts: opts}
+}
+
+// NewSftpConnectionByClient returns a new SftpConnection using an existing client.
+// The connection can't be re-established if it's dropped.
+func NewSftpConnectionByClient(client *sftp.Client) *SftpConnection {
+	return &SftpConnection{client: client}
+}
+
+// Client returns the client, connecting to the remote server if necessary.
+func (c *SftpConnection) Client() (*sftp.Client, error) {
+	if c.client == nil {
+		client, err := SftpConnect(c.params, c.opts...)
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+	return c.client, nil
+}
+
+// Retry calls f with the client. If f fails because the connection was dropped, the
+// connection is re-established and f is called again, up to MaxReconnects times.
+// f must be safe to call again after a partial failure.
+func (c *SftpConnection) Retry(f func(client *sftp.Client) error) error {
+	for attempt := 0; ; attempt++ {
+		client, err := c.Client()
+		if err == nil {
+			if err = f(client); err == nil || c.alive() {
+				return err
+			}
+		}
+		if c.params == nil || attempt >= c.params.MaxReconnects {
+			return err
+		}
+		logger.Error("SftpConnection: reconnecting to", c.params.Server, "after error -", err.Error())
+		c.Close()
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+// alive reports whether the connection is still usable.
+func (c *SftpConnection) alive() bool {
+	_, err := c.client.Getwd()
+	return err == nil
+}
+
+// Close closes the connection, if it's open.
+func (c *SftpConnection) Close() error {
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
 }
 
 // SftpKeyAuth generates an ssh.AuthMethod given the path of a private key
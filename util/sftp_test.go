@@ -0,0 +1,231 @@
+package util_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/rhansen2/ratchet/logger"
+	"github.com/rhansen2/ratchet/util"
+	"golang.org/x/crypto/ssh"
+)
+
+func newHostKey(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestSftpPinnedHostKey(t *testing.T) {
+	hostKey := newHostKey(t).PublicKey()
+	otherKey := newHostKey(t).PublicKey()
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+
+	for _, pinned := range []string{
+		string(ssh.MarshalAuthorizedKey(hostKey)),
+		ssh.FingerprintSHA256(hostKey),
+	} {
+		callback, err := util.SftpPinnedHostKey(pinned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := callback("localhost:22", addr, hostKey); err != nil {
+			t.Errorf("pinned %q rejected its key: %v", pinned, err)
+		}
+		if err := callback("localhost:22", addr, otherKey); err == nil {
+			t.Errorf("pinned %q accepted another key", pinned)
+		}
+	}
+	if _, err := util.SftpPinnedHostKey("not a key"); err == nil {
+		t.Error("no error for an invalid key")
+	}
+}
+
+// sftpServer is an in-memory sftp server, accepting any password.
+type sftpServer struct {
+	addr     string
+	hostKey  ssh.PublicKey
+	listener net.Listener
+	handlers sftp.Handlers
+	mu       sync.Mutex
+	conns    []net.Conn
+	accepted int
+}
+
+func startSftpServer(t *testing.T) *sftpServer {
+	t.Helper()
+	signer := newHostKey(t)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sftpServer{addr: l.Addr().String(), hostKey: signer.PublicKey(), listener: l, handlers: sftp.InMemHandler()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.accepted++
+			s.mu.Unlock()
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *sftpServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && strings.HasSuffix(string(req.Payload), "sftp")
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						sftp.NewRequestServer(channel, s.handlers).Serve()
+						channel.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// drop closes every connection, as if the network went down.
+func (s *sftpServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *sftpServer) close() {
+	s.listener.Close()
+	s.drop()
+}
+
+func (s *sftpServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+func (s *sftpServer) params(maxReconnects int) *util.SftpParameters {
+	return &util.SftpParameters{
+		Server:      s.addr,
+		Username:    "ratchet",
+		AuthMethods: []ssh.AuthMethod{ssh.Password("secret")},
+		SftpOptions: util.SftpOptions{HostKeyCallback: ssh.FixedHostKey(s.hostKey), MaxReconnects: maxReconnects},
+	}
+}
+
+func TestSftpConnectionRetry(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	server := startSftpServer(t)
+	defer server.close()
+	conn := util.NewSftpConnection(server.params(1))
+	defer conn.Close()
+
+	calls := 0
+	err := conn.Retry(func(client *sftp.Client) error {
+		calls++
+		if calls == 1 {
+			server.drop()
+			_, err := client.Getwd()
+			return err
+		}
+		_, err := client.Getwd()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || server.connections() != 2 {
+		t.Errorf("f called %d times over %d connections, want 2 over 2", calls, server.connections())
+	}
+
+	// Errors that aren't from a dropped connection aren't retried.
+	calls = 0
+	failed := errors.New("failed")
+	err = conn.Retry(func(client *sftp.Client) error {
+		calls++
+		return failed
+	})
+	if err != failed || calls != 1 {
+		t.Errorf("got error %v after %d calls, want %v after 1", err, calls, failed)
+	}
+}
+
+func TestSftpConnectionRetryGivesUp(t *testing.T) {
+	logger.LogLevel = logger.LevelSilent
+	server := startSftpServer(t)
+	defer server.close()
+	conn := util.NewSftpConnection(server.params(0))
+	defer conn.Close()
+
+	calls := 0
+	err := conn.Retry(func(client *sftp.Client) error {
+		calls++
+		server.drop()
+		_, err := client.Getwd()
+		return err
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("got error %v after %d calls, want an error after 1", err, calls)
+	}
+}
+
+func TestSftpConnectHostKey(t *testing.T) {
+	server := startSftpServer(t)
+	defer server.close()
+
+	params := server.params(0)
+	params.HostKeyCallback = ssh.FixedHostKey(newHostKey(t).PublicKey())
+	if client, err := util.SftpConnect(params); err == nil {
+		client.Close()
+		t.Fatal("connected to a server with the wrong host key")
+	}
+
+	params.HostKeyCallback = nil
+	params.InsecureIgnoreHostKey = true
+	client, err := util.SftpConnect(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+}
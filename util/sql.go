@@ -171,6 +171,43 @@ func sendErr(err error, dataChan chan data.JSON) {
 	dataChan <- []byte(`{"Error":"` + err.Error() + `"}`)
 }
 
+// SQLQueryObjects runs the query with the given args, returning each row as an
+// object keyed by column name. Like GetDataFromSQLQuery, []byte values are
+// converted to strings. The query is cancelled if ctx is done.
+func SQLQueryObjects(db *sql.DB, query string, ctx context.Context, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []map[string]interface{}{}
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range columns {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		object := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				object[col] = string(b)
+			} else {
+				object[col] = values[i]
+			}
+		}
+		objects = append(objects, object)
+	}
+	return objects, rows.Err()
+}
+
 // ExecuteSQLQuery allows you to execute arbitrary SQL statements
 func ExecuteSQLQuery(db *sql.DB, query string) error {
 	stmt, err := db.Prepare(query)
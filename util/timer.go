@@ -2,11 +2,14 @@ package util
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
 // Timer is a basic mechanism for measuring execution time.
+// It's safe to read a Timer from other goroutines while it's running.
 type Timer struct {
+	mu        sync.RWMutex
 	startTime time.Time
 	endTime   time.Time
 }
@@ -16,22 +19,49 @@ func StartTimer() (t *Timer) {
 	return &Timer{startTime: time.Now()}
 }
 
+// Start (re)starts the Timer.
+func (t *Timer) Start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.startTime = time.Now()
+	t.endTime = time.Time{}
+}
+
 // Stop sets the end time for the Timer and returns itself.
 func (t *Timer) Stop() *Timer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.endTime = time.Now()
 	return t
 }
 
 // Stopped returns true if Stop() has been called on the timer.
 func (t *Timer) Stopped() bool {
-	zeroTime := time.Time{}
-	return zeroTime != t.endTime
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return !t.endTime.IsZero()
+}
+
+// StartTime returns the time the Timer was started.
+func (t *Timer) StartTime() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.startTime
+}
+
+// EndTime returns the time the Timer was stopped, or the zero time if it's still running.
+func (t *Timer) EndTime() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.endTime
 }
 
 // Duration returns either the total executino duration (if Timer stopped)
 // or the duration until time.Now() if timer is still running.
 func (t *Timer) Duration() time.Duration {
-	if t.Stopped() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.endTime.IsZero() {
 		return t.endTime.Sub(t.startTime)
 	}
 	return time.Now().Sub(t.startTime)